@@ -3,7 +3,9 @@ package aws
 import (
 	"bytes"
 	"context"
+	"io"
 	"log"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,8 +13,13 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// LoadAwsConifg loads the default aws config, in AWS_REGION or ap-south-1
 func LoadAwsConifg() aws.Config {
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("ap-south-1"))
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "ap-south-1"
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
 	if err != nil {
 		log.Fatalf("AWS config error: %v", err)
 	}
@@ -46,13 +53,13 @@ func (s *S3Service) GetPresignDownloadURL(ctx context.Context, bucketName, keyNa
 }
 
 // GetPresignUploadURL return upload image pre-signed url to client
-func (s *S3Service) GetPresignUploadURL(ctx context.Context, bucketName, keyName string) (string, error) {
+func (s *S3Service) GetPresignUploadURL(ctx context.Context, bucketName, keyName string, expires time.Duration) (string, error) {
 	presignClient := s3.NewPresignClient(s.client)
 
 	presignURL, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(keyName),
-	}, s3.WithPresignExpires(10*time.Minute))
+	}, s3.WithPresignExpires(expires))
 
 	if err != nil {
 		return "", err
@@ -71,3 +78,28 @@ func (s *S3Service) UploadObject(ctx context.Context, bucketName, keyName string
 	return err
 }
 
+// GetObject returns the content & content type of an object
+func (s *S3Service) GetObject(ctx context.Context, bucketName, keyName string) ([]byte, string, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucketName,
+		Key:    &keyName,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, aws.ToString(out.ContentType), nil
+}
+
+func (s *S3Service) DeleteObject(ctx context.Context, bucketName, keyName string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &bucketName,
+		Key:    &keyName,
+	})
+	return err
+}
@@ -2,42 +2,95 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-sql-driver/mysql"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/joho/godotenv"
-	cloud "github.com/yeshu2004/go-event-booking/aws"
 	"github.com/yeshu2004/go-event-booking/models"
+	"github.com/yeshu2004/go-event-booking/service/blob"
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/digest"
+	"github.com/yeshu2004/go-event-booking/service/geo"
+	"github.com/yeshu2004/go-event-booking/service/mail"
 	"github.com/yeshu2004/go-event-booking/service/nats"
+	"github.com/yeshu2004/go-event-booking/service/outbox"
+	"github.com/yeshu2004/go-event-booking/service/payment"
+	"github.com/yeshu2004/go-event-booking/service/refund"
+	"github.com/yeshu2004/go-event-booking/service/reminder"
+	"github.com/yeshu2004/go-event-booking/service/ticket"
 	"github.com/yeshu2004/go-event-booking/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	defaultCursor int    = 0
-	defaultLimit  int    = 0
-	awsBucketName string = "ticket-one"
+	defaultCursor int = 0
+	defaultLimit  int = 0
+
+	defaultCurrency string = "INR"
+
+	seatHoldTTL      time.Duration = 10 * time.Minute
+	waitlistOfferTTL time.Duration = 30 * time.Minute
+	paymentTTL       time.Duration = 15 * time.Minute
+
+	admissionTokenTTL time.Duration = 10 * time.Minute
+
+	// queued booking requests allocated per batch
+	allocationBatchSize int = 100
+	// deliveries of a message on the in-memory bus before it is dead-lettered
+	memoryBusMaxAttempts int = 5
+	// bookings of a cancelled event cancelled per tx
+	eventCancelBatchSize int = 200
+
+	// uploads through the local blob store
+	maxBlobUploadBytes int64 = 10 << 20
 )
 
 var cloudFrontURL string
 
+var (
+	errEventNotFound   = errors.New("event not found")
+	errNotEnoughSeats  = errors.New("not enough seats available")
+	errHoldNotFound    = errors.New("seat hold not found or expired")
+	errHoldConfirmed   = errors.New("seat hold is already confirmed")
+	errTierRequired    = errors.New("tier_id is required for this event")
+	errTierNotFound    = errors.New("ticket tier not found")
+	errTierNotOnSale   = errors.New("ticket tier is not on sale")
+	errNotPayable      = errors.New("booking is not awaiting payment")
+	errSeatsPerBooking = models.ErrSeatsPerBooking
+	errUserLimit       = models.ErrUserLimit
+	errEventCancelled  = errors.New("event has been cancelled")
+)
+
 type Handler struct {
 	db          *sql.DB
 	redisClient *storage.RedisServer
-	s3          *cloud.S3Service
-	natsIns     *nats.NATSIns
+	blobs       blob.Store
+	mailer      mail.Mailer
+	msgBus      bus.Bus
+	payments    payment.Gateway
+	geocoder    geo.Geocoder // nil when geocoding is off
 }
 
 type AuthInput struct {
@@ -239,9 +292,62 @@ func (h *Handler) loginUser(c *gin.Context) {
 
 }
 
-// for user -- not in use
-func (h *Handler) subscribeHandler(c *gin.Context) {
-	// get id from middlware
+// followOrganizationHandler makes the user follow an organization, they
+// are mailed about its new public events
+func (h *Handler) followOrganizationHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	currentUser, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	user := currentUser.(models.User)
+
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid Organization ID",
+		})
+		return
+	}
+
+	var id int64
+	if err := h.db.QueryRowContext(ctx, "SELECT id FROM organization WHERE id = ?", orgId).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// following twice is a no-op
+	query := "INSERT INTO subscription (user_id, org_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = id"
+	if _, err = h.db.ExecContext(ctx, query, user.Id, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "subscription err: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "following organization",
+		"data": gin.H{
+			"user_id": user.Id,
+			"org_id":  id,
+		},
+	})
+}
+
+// unfollowOrganizationHandler stops the user following an organization
+func (h *Handler) unfollowOrganizationHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
 	currentUser, exists := c.Get("current_user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -251,8 +357,7 @@ func (h *Handler) subscribeHandler(c *gin.Context) {
 	}
 	user := currentUser.(models.User)
 
-	orgStrId := c.Param("org_id")
-	orgId, err := strconv.Atoi(orgStrId)
+	orgId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid Organization ID",
@@ -260,22 +365,208 @@ func (h *Handler) subscribeHandler(c *gin.Context) {
 		return
 	}
 
-	query := "INSERT INTO subscription (user_id, org_id, subscribed, subscribed_at) VALUES (?, ?, ?, ?)"
-	if _, err = h.db.Exec(query, user.Id, orgId, true, time.Now()); err != nil {
+	res, err := h.db.ExecContext(ctx, "DELETE FROM subscription WHERE user_id = ? AND org_id = ?", user.Id, orgId)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "subscription err: " + err.Error(),
 		})
 		return
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "not following this organization",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "subscribed to event successfully",
+		"message": "unfollowed organization",
 		"data": gin.H{
 			"user_id": user.Id,
 			"org_id":  orgId,
 		},
 	})
+}
+
+// listFollowingHandler returns the organizations the user follows
+func (h *Handler) listFollowingHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	currentUser, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	user := currentUser.(models.User)
+
+	query := `SELECT o.id, o.org_name, o.description, s.subscribed_at FROM subscription s
+		JOIN organization o ON o.id = s.org_id
+		WHERE s.user_id = ? ORDER BY s.subscribed_at DESC`
+	rows, err := h.db.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	following := make([]models.FollowedOrganization, 0)
+	for rows.Next() {
+		var f models.FollowedOrganization
+		if err := rows.Scan(&f.OrgId, &f.OrgName, &f.Description, &f.SubscribedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		following = append(following, f)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "followed organizations",
+		"data":    following,
+		"count":   len(following),
+	})
+}
+
+// getFollowerCountHandler shows the organizer how many users follow them
+func (h *Handler) getFollowerCountHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+	org := o.(models.Organization)
+
+	var total, lastWeek int64
+	query := "SELECT COUNT(*), COALESCE(SUM(subscribed_at >= ?), 0) FROM subscription WHERE org_id = ?"
+	if err := h.db.QueryRowContext(ctx, query, time.Now().AddDate(0, 0, -7), org.Id).Scan(&total, &lastWeek); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "follower count",
+		"data": gin.H{
+			"org_id":          org.Id,
+			"follower_count":  total,
+			"new_last_7_days": lastWeek,
+		},
+	})
+}
+
+// getDigestSettingsHandler returns the weekly digest settings of the user
+func (h *Handler) getDigestSettingsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	currentUser, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	user := currentUser.(models.User)
+
+	settings, err := h.loadDigestSettings(ctx, user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "digest settings",
+		"data":    settings,
+	})
+}
+
+// updateDigestSettingsHandler opts the user in/out of the weekly digest
+// and sets the city it covers
+func (h *Handler) updateDigestSettingsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	currentUser, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	user := currentUser.(models.User)
+
+	var req models.DigestSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.loadDigestSettings(ctx, user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OptedOut != nil {
+		settings.OptedOut = *req.OptedOut
+	}
+	if req.City != nil {
+		// empty city goes back to the city of the latest booking
+		settings.City = nil
+		if city := strings.TrimSpace(*req.City); city != "" {
+			settings.City = &city
+		}
+	}
+
+	query := "INSERT INTO user_digest (user_id, opted_out, city) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE opted_out = VALUES(opted_out), city = VALUES(city)"
+	if _, err := h.db.ExecContext(ctx, query, user.Id, settings.OptedOut, settings.City); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "digest settings updated",
+		"data":    settings,
+	})
+}
+
+// digestUnsubscribeHandler is the unsubscribe link of the digest mail,
+// it works without logging in as the token is signed
+func (h *Handler) digestUnsubscribeHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	userId, err := digest.VerifyUnsubscribeToken(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := "INSERT INTO user_digest (user_id, opted_out) VALUES (?, TRUE) ON DUPLICATE KEY UPDATE opted_out = TRUE"
+	if _, err := h.db.ExecContext(ctx, query, userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "unsubscribed from the weekly digest",
+	})
+}
 
+func (h *Handler) loadDigestSettings(ctx context.Context, userId int64) (*models.DigestSettings, error) {
+	settings := &models.DigestSettings{UserId: userId}
+	err := h.db.QueryRowContext(ctx, "SELECT opted_out, city, last_sent_at FROM user_digest WHERE user_id = ?", userId).Scan(&settings.OptedOut, &settings.City, &settings.LastSentAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return settings, nil
 }
 
 // for organization
@@ -500,9 +791,50 @@ func (h *Handler) createEventHandler(c *gin.Context) {
 	newEvent.State = strings.TrimSpace(newEvent.State)
 	newEvent.Country = strings.TrimSpace(newEvent.Country)
 	newEvent.Name = strings.TrimSpace(newEvent.Name)
+	newEvent.Description = strings.TrimSpace(newEvent.Description)
+
+	if err := newEvent.BookingLimits.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := newEvent.EventTags.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// image has to be one uploaded through getPresignedUrl by this org
+	if newEvent.Key != "" && !blob.IsEventImageKey(org.Id, newEvent.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image key"})
+		return
+	}
+
+	// coordinates sent by the organizer win, else the address is geocoded
+	point, err := pointOf(newEvent.Latitude, newEvent.Longitude)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if point == nil {
+		point = h.geocode(ctx, geo.Address{Street: newEvent.Address, City: newEvent.City, State: newEvent.State, Country: newEvent.Country})
+	}
+	newEvent.Latitude, newEvent.Longitude = nil, nil
+	if point != nil {
+		newEvent.Latitude, newEvent.Longitude = &point.Lat, &point.Lng
+	}
+
+	// followers are notified through the outbox, so in the same tx
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
 
-	query := "INSERT INTO event (name, org_id, organized_by, image_key, capacity, date, address, city, state, country, visible) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
-	res, err := h.db.ExecContext(ctx, query, newEvent.Name, org.Id, org.OrgName, newEvent.Key, newEvent.Capacity, newEvent.Date, newEvent.Address, newEvent.City, newEvent.State, newEvent.Country, newEvent.Visible)
+	query := "INSERT INTO event (name, description, org_id, organized_by, image_key, capacity, date, address, city, state, country, latitude, longitude, visible, max_seats_per_booking, max_seats_per_user, max_bookings_per_user) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := tx.ExecContext(ctx, query, newEvent.Name, newEvent.Description, org.Id, org.OrgName, newEvent.Key, newEvent.Capacity, newEvent.Date, newEvent.Address, newEvent.City, newEvent.State, newEvent.Country, newEvent.Latitude, newEvent.Longitude, newEvent.Visible, newEvent.MaxSeatsPerBooking, newEvent.MaxSeatsPerUser, newEvent.MaxBookingsPerUser)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			c.JSON(http.StatusRequestTimeout, gin.H{
@@ -528,6 +860,30 @@ func (h *Handler) createEventHandler(c *gin.Context) {
 		return
 	}
 
+	if err := saveEventTags(ctx, tx, id, newEvent.EventTags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to save event tags: " + err.Error(),
+		})
+		return
+	}
+
+	// private events are only announced to followers once made public
+	if newEvent.Visible == "PUBLIC" {
+		if err := addNewEventMessage(ctx, tx, id, org, newEvent); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to queue follower notification: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to commit transaction",
+		})
+		return
+	}
+
 	// if event is set to be active(public), update redis version
 	if newEvent.Visible == "PUBLIC" {
 		if h.redisClient != nil {
@@ -545,6 +901,7 @@ func (h *Handler) createEventHandler(c *gin.Context) {
 		"data": gin.H{
 			"id":              id,
 			"name":            newEvent.Name,
+			"description":     newEvent.Description,
 			"orgId":           org.Id,
 			"organizedBy":     org.OrgName,
 			"imageKey":        newEvent.Key,
@@ -555,11 +912,58 @@ func (h *Handler) createEventHandler(c *gin.Context) {
 			"city":            newEvent.City,
 			"state":           newEvent.State,
 			"county":          newEvent.Country,
+			"latitude":        newEvent.Latitude,
+			"longitude":       newEvent.Longitude,
 			"visible":         newEvent.Visible,
+			"limits":          newEvent.BookingLimits,
+			"categories":      newEvent.Categories,
+			"tags":            newEvent.Tags,
 		},
 	})
 }
 
+// addNewEventMessage queues a mail to every follower of org about its new
+// event in the outbox of tx. A message per follower keeps payloads small
+// and a failed mail is retried without mailing the others again.
+func addNewEventMessage(ctx context.Context, tx *sql.Tx, eventID int64, org models.Organization, e models.Event) error {
+	rows, err := tx.QueryContext(ctx, "SELECT u.id, u.first_name, u.email FROM subscription s JOIN user u ON u.id = s.user_id WHERE s.org_id = ?", org.Id)
+	if err != nil {
+		return err
+	}
+	var followers []models.Follower
+	for rows.Next() {
+		var f models.Follower
+		if err := rows.Scan(&f.UserID, &f.Name, &f.Email); err != nil {
+			rows.Close()
+			return err
+		}
+		followers = append(followers, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range followers {
+		payload, err := json.Marshal(models.NewEventPayload{
+			EventID:   eventID,
+			EventName: e.Name,
+			EventDate: e.Date,
+			City:      e.City,
+			OrgID:     org.Id,
+			OrgName:   org.OrgName,
+			To:        f,
+		})
+		if err != nil {
+			return err
+		}
+		if err := outbox.Add(ctx, tx, bus.SubjectEventNew, bus.NewEventMsgID(eventID, f.UserID), payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *Handler) aboutOrganization(c *gin.Context) {
 	strId := c.Param("id")
 	orgId, err := strconv.Atoi(strId)
@@ -581,6 +985,12 @@ func (h *Handler) aboutOrganization(c *gin.Context) {
 		return
 	}
 
+	var followerCount int64
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM subscription WHERE org_id = ?", orgId).Scan(&followerCount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch follower count"})
+		return
+	}
+
 	eventQuery := "SELECT id, name, date, city, state, country, created_at FROM event WHERE org_id = ?"
 	rows, err := h.db.Query(eventQuery, orgId)
 	if err != nil {
@@ -606,557 +1016,4352 @@ func (h *Handler) aboutOrganization(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "successful",
 		"data": gin.H{
-			"organization": org,
-			"events":       events,
+			"organization":   org,
+			"events":         events,
+			"follower_count": followerCount,
 		},
 	})
 }
 
-// handler to mark event delete & update redis cache version
-func (h *Handler) DeletEvenHandler(c *gin.Context) {
+// createTicketTierHandler adds a ticket type (General, VIP, Early Bird..)
+// to an event of the organization, tiers split the event capacity
+func (h *Handler) createTicketTierHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	// delete
-	//1. get event id from params
-	i := c.Param("id")
-	id, err := strconv.Atoi(i)
-	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{
-			"error": fmt.Sprintf("wrong event id or conversion error: %v", err),
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
 		})
 		return
 	}
+	org := o.(models.Organization)
 
-	//2. mark event
-	query := "UPDATE event SET visible = 'DELETE' WHERE id = ?"
-	_, err = h.db.ExecContext(ctx, query, id)
+	eventId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{
-			"error": fmt.Sprintf("db error: %v", err),
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
 		})
 		return
 	}
 
-	//3. cache version update
-	if h.redisClient != nil {
-		if err := h.redisClient.UpdateEventVersion(ctx); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("redis cache version update error: %v", err),
-			})
-			return
-		}
+	var req models.TicketTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid input: " + err.Error(),
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("event deleted with event_id (%d)", id),
+	req.Name = strings.TrimSpace(req.Name)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = defaultCurrency
+	}
+
+	if req.Name == "" || req.Capacity <= 0 || req.Price < 0 || len(req.Currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "tier needs a name, capacity > 0, price >= 0 and a 3 letter currency",
+		})
+		return
+	}
+	if req.SaleStartsAt != nil && req.SaleEndsAt != nil && !req.SaleEndsAt.After(*req.SaleStartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "sale_ends_at must be after sale_starts_at",
+		})
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// lock event so tiers never add up to more than its capacity
+	var capacity int64
+	err = tx.QueryRowContext(ctx, "SELECT capacity FROM event WHERE id = ? AND org_id = ? FOR UPDATE", eventId, org.Id).Scan(&capacity)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event not found",
+		})
+		return
+	}
+
+	var tierCapacity int64
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(capacity), 0) FROM ticket_tier WHERE event_id = ?", eventId).Scan(&tierCapacity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tierCapacity+req.Capacity > capacity {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("only %d seats of the event capacity are left for new tiers", capacity-tierCapacity),
+		})
+		return
+	}
+
+	query := "INSERT INTO ticket_tier (event_id, name, price, currency, capacity, seats_available, sale_starts_at, sale_ends_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := tx.ExecContext(ctx, query, eventId, req.Name, req.Price, req.Currency, req.Capacity, req.Capacity, req.SaleStartsAt, req.SaleEndsAt)
+	if err != nil {
+		if isDuplicateKeyErr(err) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "tier with this name already exists for the event",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to commit transaction",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "ticket tier created successfully",
+		"data": models.TicketTier{
+			Id:             id,
+			EventId:        int64(eventId),
+			Name:           req.Name,
+			Price:          req.Price,
+			Currency:       req.Currency,
+			Capacity:       req.Capacity,
+			SeatsAvailable: req.Capacity,
+			SaleStartsAt:   req.SaleStartsAt,
+			SaleEndsAt:     req.SaleEndsAt,
+		},
+	})
+}
+
+// listTicketTiersHandler lists ticket tiers of an event with seats left
+func (h *Handler) listTicketTiersHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	query := "SELECT id, event_id, name, price, currency, capacity, seats_available, sale_starts_at, sale_ends_at, created_at FROM ticket_tier WHERE event_id = ? ORDER BY price ASC, id ASC"
+	rows, err := h.db.QueryContext(ctx, query, eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to query ticket tiers",
+		})
+		return
+	}
+	defer rows.Close()
+
+	tiers := make([]models.TicketTier, 0)
+	for rows.Next() {
+		var t models.TicketTier
+		if err := rows.Scan(&t.Id, &t.EventId, &t.Name, &t.Price, &t.Currency, &t.Capacity, &t.SeatsAvailable, &t.SaleStartsAt, &t.SaleEndsAt, &t.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "row scan error:" + err.Error(),
+			})
+			return
+		}
+		tiers = append(tiers, t)
+	}
+
+	// seats on hold are not bookable by others
+	if h.redisClient != nil {
+		holds, err := h.redisClient.GetActiveHolds(ctx, int64(eventId))
+		if err != nil {
+			log.Printf("failed to read seat holds for event %d: %v", eventId, err)
+		}
+		for i := range tiers {
+			for _, hold := range holds {
+				if hold.TierID == tiers[i].Id {
+					tiers[i].SeatsAvailable -= hold.Seats
+				}
+			}
+			if tiers[i].SeatsAvailable < 0 {
+				tiers[i].SeatsAvailable = 0
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ticket tiers retrieved",
+		"data":    tiers,
+	})
+}
+
+// cancelEventHandler cancels an event for good, the event is marked
+// cancelled right away and its bookings are cancelled, refunded in full
+// & their attendees mailed in batches, progress is at
+// getEventCancellationHandler
+func (h *Handler) cancelEventHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+	org := o.(models.Organization)
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	// body is optional
+	var req models.CancelEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "json binding error:" + err.Error(),
+		})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be at most 500 characters"})
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// bookings lock the event row too, none can slip in after this
+	var visible string
+	err = tx.QueryRowContext(ctx, "SELECT visible FROM event WHERE id = ? AND org_id = ? FOR UPDATE", eventId, org.Id).Scan(&visible)
+	if err != nil || visible == "DELETED" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event not found",
+		})
+		return
+	}
+
+	if visible == "CANCELLED" {
+		tx.Commit()
+		progress, err := h.loadEventCancellation(ctx, int64(eventId))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "event already cancelled",
+			"alreadyCancelled": true,
+			"data":             progress,
+		})
+		return
+	}
+
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE event SET visible = 'CANCELLED', cancelled_at = ?, cancel_reason = ? WHERE id = ?", time.Now(), reason, eventId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bookingsTotal int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM booking WHERE event_id = ? AND status IN ('CONFIRMED', 'PENDING_PAYMENT')", eventId).Scan(&bookingsTotal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO event_cancellation (event_id, reason, bookings_total) VALUES (?, ?, ?)", eventId, reason, bookingsTotal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// nobody is offered seats of a cancelled event anymore
+	if _, err := tx.ExecContext(ctx, "UPDATE waitlist SET status = 'EXPIRED' WHERE event_id = ? AND status IN ('WAITING', 'OFFERED')", eventId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
+		return
+	}
+
+	// cancelled events drop out of the cached listings
+	if h.redisClient != nil {
+		if err := h.redisClient.UpdateEventVersion(ctx); err != nil {
+			log.Printf("redis cache version update error: %v", err)
+		}
+	}
+
+	// the sweeper picks it up again if this instance goes down midway
+	go func() {
+		if err := h.processEventCancellation(context.Background(), int64(eventId)); err != nil {
+			log.Printf("failed to process cancellation of event %d: %v", eventId, err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": fmt.Sprintf("event (%d) cancelled, bookings are being cancelled & refunded", eventId),
+		"data": gin.H{
+			"event_id":       eventId,
+			"bookings_total": bookingsTotal,
+			"status":         "IN_PROGRESS",
+			"progress_url":   fmt.Sprintf("/api/event/%d/cancellation", eventId),
+		},
+	})
+}
+
+// getEventCancellationHandler shows the organizer how far the
+// cancellation of an event got
+func (h *Handler) getEventCancellationHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+	org := o.(models.Organization)
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	var id int64
+	if err := h.db.QueryRowContext(ctx, "SELECT id FROM event WHERE id = ? AND org_id = ?", eventId, org.Id).Scan(&id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event not found",
+		})
+		return
+	}
+
+	progress, err := h.loadEventCancellation(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if progress == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event is not cancelled",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "event cancellation progress",
+		"data":    progress,
+	})
+}
+
+// loadEventCancellation returns the cancellation of an event with its
+// refunds by status, nil if the event isn't cancelled
+func (h *Handler) loadEventCancellation(ctx context.Context, eventID int64) (*models.EventCancellation, error) {
+	var p models.EventCancellation
+	query := "SELECT event_id, reason, status, bookings_total, bookings_cancelled, refunds_created, notifications_queued, started_at, completed_at FROM event_cancellation WHERE event_id = ?"
+	err := h.db.QueryRowContext(ctx, query, eventID).Scan(&p.EventId, &p.Reason, &p.Status, &p.BookingsTotal, &p.BookingsCancelled, &p.RefundsCreated, &p.NotificationsQueued, &p.StartedAt, &p.CompletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// refunds are processed by the refund worker, their status is live
+	rows, err := h.db.QueryContext(ctx, `SELECT r.status, COUNT(*), COALESCE(SUM(r.amount), 0) FROM refund r
+		JOIN booking b ON b.id = r.booking_id
+		WHERE b.event_id = ? AND r.created_at >= ? GROUP BY r.status`, eventID, p.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.RefundProgress
+		if err := rows.Scan(&r.Status, &r.Count, &r.Amount); err != nil {
+			return nil, err
+		}
+		p.Refunds = append(p.Refunds, r)
+	}
+	return &p, rows.Err()
+}
+
+// processEventCancellation cancels the bookings of a cancelled event
+// batch by batch until none are left. Several instances can work on the
+// same event, booking rows are claimed with SKIP LOCKED.
+func (h *Handler) processEventCancellation(ctx context.Context, eventID int64) error {
+	for {
+		n, err := h.cancelEventBookingsBatch(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	// rows locked by another instance were skipped, it completes then
+	var left int
+	if err := h.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM booking WHERE event_id = ? AND status IN ('CONFIRMED', 'PENDING_PAYMENT')", eventID).Scan(&left); err != nil {
+		return err
+	}
+	if left > 0 {
+		return nil
+	}
+
+	_, err := h.db.ExecContext(ctx, "UPDATE event_cancellation SET status = 'COMPLETED', completed_at = ? WHERE event_id = ? AND status = 'IN_PROGRESS'", time.Now(), eventID)
+	return err
+}
+
+// cancelEventBookingsBatch cancels one batch of bookings of a cancelled
+// event and returns how many, paid bookings are refunded in full whatever
+// the refund policy says
+func (h *Handler) cancelEventBookingsBatch(ctx context.Context, eventID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var eventName string
+	var eventDate time.Time
+	var reason sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT name, date, cancel_reason FROM event WHERE id = ?", eventID).Scan(&eventName, &eventDate, &reason); err != nil {
+		return 0, err
+	}
+
+	query := `SELECT b.id, b.status, b.seats, b.amount, b.currency, b.payment_intent_id, u.first_name, u.email
+		FROM booking b JOIN user u ON u.id = b.user_id
+		WHERE b.event_id = ? AND b.status IN ('CONFIRMED', 'PENDING_PAYMENT')
+		ORDER BY b.id LIMIT ? FOR UPDATE OF b SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, eventID, eventCancelBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type booking struct {
+		id       int64
+		status   string
+		seats    int64
+		amount   int64
+		currency string
+		intentID sql.NullString
+		name     string
+		email    string
+	}
+	var bookings []booking
+	for rows.Next() {
+		var b booking
+		if err := rows.Scan(&b.id, &b.status, &b.seats, &b.amount, &b.currency, &b.intentID, &b.name, &b.email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		bookings = append(bookings, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(bookings) == 0 {
+		return 0, nil
+	}
+
+	refunds := 0
+	for _, b := range bookings {
+		if _, err := tx.ExecContext(ctx, "UPDATE booking SET status = 'CANCELLED' WHERE id = ?", b.id); err != nil {
+			return 0, err
+		}
+
+		res, err := tx.ExecContext(ctx, "INSERT INTO booking_cancellation (booking_id, seats, remaining_seats) VALUES (?, ?, 0)", b.id, b.seats)
+		if err != nil {
+			return 0, err
+		}
+		cancellationID, err := res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+
+		if err := reminder.Cancel(ctx, tx, b.id); err != nil {
+			return 0, err
+		}
+
+		p := models.EventCancelledPayload{
+			EventID:   eventID,
+			BookingID: b.id,
+			EventName: eventName,
+			EventDate: eventDate,
+			Reason:    reason.String,
+			UserName:  b.name,
+			UserEmail: b.email,
+			Seats:     b.seats,
+			Currency:  b.currency,
+		}
+
+		// an unpaid booking that gets paid later is refunded by
+		// refundUnpayableBooking
+		if b.status == "CONFIRMED" && b.intentID.Valid && b.amount > 0 {
+			refund, err := createRefundTx(ctx, tx, b.id, &cancellationID, b.intentID.String, b.amount, b.currency, 100)
+			if err != nil {
+				return 0, err
+			}
+			p.RefundAmount = refund.Amount
+			err = addRefundMessage(ctx, tx, models.RefundPayload{
+				RefundID:  refund.Id,
+				BookingID: b.id,
+				UserName:  b.name,
+				UserEmail: b.email,
+				EventName: eventName,
+			})
+			if err != nil {
+				return 0, err
+			}
+			refunds++
+		}
+
+		payload, err := json.Marshal(p)
+		if err != nil {
+			return 0, err
+		}
+		if err := outbox.Add(ctx, tx, bus.SubjectEventCancelled, bus.EventCancelledMsgID(eventID, b.id), payload); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE event_cancellation
+		SET bookings_cancelled = bookings_cancelled + ?, refunds_created = refunds_created + ?, notifications_queued = notifications_queued + ?
+		WHERE event_id = ?`, len(bookings), refunds, len(bookings), eventID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(bookings), nil
+}
+
+// runEventCancellationSweeper resumes event cancellations left unfinished,
+// e.g. when the instance that started one went down
+func (h *Handler) runEventCancellationSweeper(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rows, err := h.db.QueryContext(ctx, "SELECT event_id FROM event_cancellation WHERE status = 'IN_PROGRESS'")
+			if err != nil {
+				log.Printf("event cancellation sweep error: %v", err)
+				continue
+			}
+
+			var eventIds []int64
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					log.Printf("row scan error: %v", err)
+					continue
+				}
+				eventIds = append(eventIds, id)
+			}
+			rows.Close()
+
+			for _, id := range eventIds {
+				if err := h.processEventCancellation(ctx, id); err != nil {
+					log.Printf("failed to process cancellation of event %d: %v", id, err)
+				}
+			}
+		}
+	}
+}
+
+// for user, improved: redis cache miss/hit & context
+// timeouts err with pagination.
+// trying to convert this into upcomingEventsHandler,
+// Active events only (date >= now())
+func (h *Handler) listEventHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+	defer cancel()
+
+	// retrive cursor(id), limit from client request
+	idStr := c.DefaultQuery("id", fmt.Sprintf("%v", defaultCursor))
+	limitStr := c.DefaultQuery("limit", fmt.Sprintf("%v", defaultLimit))
+	cursor, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 10 {
+		limit = 10
+	}
+
+	// browsing by category or tag
+	filter, err := parseListingFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var version int
+
+	// // cache check ->
+	if h.redisClient != nil {
+		// get verison
+		v, err := h.redisClient.GetEventVerison(ctx)
+		if err != nil || v == -1 {
+			fmt.Printf("version error on listing: %s\n", err)
+		}
+		version = v
+
+		var cacheRes []models.EventResponse
+		cachedEvents, err := h.redisClient.GetCacheEvents(ctx, v, filter, cursor, limit)
+		if err == nil && cachedEvents != nil {
+			for _, e := range cachedEvents {
+				// convert cache model to response model & generate image url
+				imageUrl := h.generateImageUrl(e.EventKey)
+				res := newEventResponse(e.EventID, e.EventName, e.EventDate, imageUrl, e.OrganizationID, e.OrganizationName, e.City)
+				res.EventTags = e.EventTags
+				cacheRes = append(cacheRes, *res)
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"message": "retrieved all events from cache",
+				"data":    cacheRes,
+			})
+			return
+		}
+	}
+
+	// cache miss -> list only event who are visible i.e public
+	query := "SELECT id, name, org_id, organized_by, image_key, capacity, seats_available, date, address, city, state, country, created_at FROM event WHERE visible = 'PUBLIC' AND date >= NOW() AND id > ?"
+	args := []interface{}{cursor}
+	if filter.Category != "" {
+		query += " AND id IN (" + taggedEvents + ")"
+		args = append(args, "CATEGORY", filter.Category)
+	}
+	if filter.Tag != "" {
+		query += " AND id IN (" + taggedEvents + ")"
+		args = append(args, "TAG", filter.Tag)
+	}
+	query += " ORDER BY id ASC LIMIT ?"
+	args = append(args, limit+1)
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusRequestTimeout, gin.H{
+				"error": "query timeout",
+			})
+			return
+		}
+
+		if errors.Is(err, context.Canceled) {
+			c.JSON(499, gin.H{
+				"error": "request canceled by client",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	cacheEvents := make([]models.EventCache, 0, limit+1)
+	respEvents := make([]models.EventResponse, 0, limit+1)
+	for rows.Next() {
+		var event models.Event
+		if err := rows.Scan(
+			&event.Id,
+			&event.Name,
+			&event.OrgId,
+			&event.OrganizedBy,
+			&event.Key,
+			&event.Capacity,
+			&event.SeatsAvailable,
+			&event.Date,
+			&event.Address,
+			&event.City,
+			&event.State,
+			&event.Country,
+			&event.CreatedAt,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to scan rows: " + err.Error(),
+			})
+			return
+		}
+
+		// response (with image URL)
+		respEvents = append(respEvents, *newEventResponse(
+			int(event.Id),
+			event.Name,
+			event.Date,
+			h.generateImageUrl(event.Key),
+			int(event.OrgId),
+			event.OrganizedBy,
+			event.City,
+		))
+
+		// cache (without image URL)
+		cacheEvents = append(cacheEvents, *newEventCache(
+			int(event.Id),
+			event.Name,
+			event.Key,
+			event.Date,
+			int(event.OrgId),
+			event.OrganizedBy,
+			event.City,
+		))
+	}
+
+	// the cached page carries the tags too
+	h.withTags(ctx, respEvents)
+	for i := range cacheEvents {
+		cacheEvents[i].EventTags = respEvents[i].EventTags
+	}
+
+	hasNext := false
+	if len(respEvents) > limit {
+		hasNext = true
+		respEvents = respEvents[:limit]
+	}
+
+	nextCursor := 0
+	if hasNext {
+		nextCursor = respEvents[len(respEvents)-1].EventID
+	}
+
+	// cache set in redis
+	if err := h.redisClient.SetEventsCache(ctx, cacheEvents, version, filter, cursor, limit); err != nil {
+		fmt.Printf("failed to set events cache: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "events retrieved",
+		"data":        respEvents,
+		"has_next":    hasNext,
+		"next_cursor": nextCursor,
+	})
+}
+
+// events having a category or tag, args are the kind & the name
+const taggedEvents = "SELECT et.event_id FROM event_tag et JOIN tag t ON t.id = et.tag_id WHERE t.kind = ? AND t.name = ?"
+
+// parseListingFilter reads the category & tag params shared by the
+// listing and search
+func parseListingFilter(c *gin.Context) (storage.ListingFilter, error) {
+	var f storage.ListingFilter
+	if v := c.Query("category"); v != "" {
+		name, err := models.NormalizeTag(v)
+		if err != nil || !models.IsCategory(name) {
+			return f, fmt.Errorf("unknown category %q", v)
+		}
+		f.Category = name
+	}
+	if v := c.Query("tag"); v != "" {
+		name, err := models.NormalizeTag(v)
+		if err != nil {
+			return f, err
+		}
+		f.Tag = name
+	}
+	return f, nil
+}
+
+// categoriesHandler lists the curated categories events are browsed by
+func categoriesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message": "event categories",
+		"data":    models.Categories,
+	})
+}
+
+// saveEventTags replaces the categories and/or tags of an event in the
+// caller's tx, a nil list is left untouched
+func saveEventTags(ctx context.Context, tx *sql.Tx, eventID int64, t models.EventTags) error {
+	if t.Categories != nil {
+		if err := setEventTags(ctx, tx, eventID, "CATEGORY", t.Categories); err != nil {
+			return err
+		}
+	}
+	if t.Tags != nil {
+		if err := setEventTags(ctx, tx, eventID, "TAG", t.Tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setEventTags(ctx context.Context, tx *sql.Tx, eventID int64, kind string, names []string) error {
+	_, err := tx.ExecContext(ctx, "DELETE et FROM event_tag et JOIN tag t ON t.id = et.tag_id WHERE et.event_id = ? AND t.kind = ?", eventID, kind)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		// tags are created on first use, LAST_INSERT_ID(id) hands back the
+		// id of an existing one
+		res, err := tx.ExecContext(ctx, "INSERT INTO tag (kind, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)", kind, name)
+		if err != nil {
+			return err
+		}
+		tagID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO event_tag (event_id, tag_id) VALUES (?, ?)", eventID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEventTags returns the categories & tags of the events by id
+func loadEventTags(ctx context.Context, db *sql.DB, ids []int) (map[int]models.EventTags, error) {
+	tags := make(map[int]models.EventTags, len(ids))
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	marks := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		marks[i] = "?"
+		args[i] = id
+	}
+
+	query := "SELECT et.event_id, t.kind, t.name FROM event_tag et JOIN tag t ON t.id = et.tag_id WHERE et.event_id IN (" + strings.Join(marks, ", ") + ") ORDER BY t.name"
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var kind, name string
+		if err := rows.Scan(&id, &kind, &name); err != nil {
+			return nil, err
+		}
+		t := tags[id]
+		if kind == "CATEGORY" {
+			t.Categories = append(t.Categories, name)
+		} else {
+			t.Tags = append(t.Tags, name)
+		}
+		tags[id] = t
+	}
+	return tags, rows.Err()
+}
+
+// withTags adds the categories & tags to listed events, on failure they
+// are only left out
+func (h *Handler) withTags(ctx context.Context, events []models.EventResponse) {
+	ids := make([]int, len(events))
+	for i, e := range events {
+		ids[i] = e.EventID
+	}
+
+	tags, err := loadEventTags(ctx, h.db, ids)
+	if err != nil {
+		log.Printf("failed to load event tags: %v", err)
+		return
+	}
+	for i := range events {
+		events[i].EventTags = tags[events[i].EventID]
+	}
+}
+
+// full-text index of event.sql, MATCH has to list its columns in order
+const eventMatch = "MATCH(e.name, e.organized_by, e.address, e.description) AGAINST (? IN BOOLEAN MODE)"
+
+// eventSearch is the parsed query string of searchEventsHandler
+type eventSearch struct {
+	Query     string
+	terms     string // boolean mode query built from Query
+	City      string
+	State     string
+	Country   string
+	From      *time.Time
+	To        *time.Time
+	OrgID     int64
+	Category  string
+	Tag       string
+	Available bool
+	Sort      string // date or relevance
+	Limit     int
+	RawCursor string
+	cursor    *models.SearchCursor
+}
+
+// parseEventSearch reads & validates the search params of the request
+func parseEventSearch(c *gin.Context) (*eventSearch, error) {
+	s := &eventSearch{
+		Query:     strings.TrimSpace(c.Query("q")),
+		City:      strings.TrimSpace(c.Query("city")),
+		State:     strings.TrimSpace(c.Query("state")),
+		Country:   strings.TrimSpace(c.Query("country")),
+		Available: c.Query("available") == "true",
+		Sort:      c.DefaultQuery("sort", "relevance"),
+		Limit:     20,
+		RawCursor: c.Query("cursor"),
+	}
+
+	if s.Query != "" {
+		s.terms = searchTerms(s.Query)
+		if s.terms == "" {
+			return nil, errors.New("q needs a word of at least 3 characters")
+		}
+	}
+	// nothing to rank without a text query
+	if s.terms == "" {
+		s.Sort = "date"
+	}
+	if s.Sort != "date" && s.Sort != "relevance" {
+		return nil, errors.New("sort must be date or relevance")
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &s.From, "to": &s.To} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := parseSearchDate(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s date, use 2006-01-02 or RFC3339", name)
+		}
+		*dst = &t
+	}
+	if s.To != nil && len(c.Query("to")) == len("2006-01-02") {
+		// a plain date includes the whole day
+		end := s.To.AddDate(0, 0, 1).Add(-time.Second)
+		s.To = &end
+	}
+
+	if v := c.Query("org_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid org_id")
+		}
+		s.OrgID = id
+	}
+
+	filter, err := parseListingFilter(c)
+	if err != nil {
+		return nil, err
+	}
+	s.Category, s.Tag = filter.Category, filter.Tag
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return nil, errors.New("invalid limit")
+		}
+		if limit > 50 {
+			limit = 50
+		}
+		s.Limit = limit
+	}
+
+	if s.RawCursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(s.RawCursor)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		var cur models.SearchCursor
+		if err := json.Unmarshal(raw, &cur); err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		s.cursor = &cur
+	}
+	return s, nil
+}
+
+func parseSearchDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// searchTerms turns free text into a boolean mode query where every word
+// must match as a prefix, operators typed by the user are dropped and so
+// are words shorter than the innodb full-text minimum
+func searchTerms(q string) string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if len([]rune(w)) < 3 {
+			continue
+		}
+		terms = append(terms, "+"+w+"*")
+	}
+	return strings.Join(terms, " ")
+}
+
+// where builds the filter shared by the page & facet queries
+func (s *eventSearch) where() (string, []interface{}) {
+	conds := []string{"e.visible = 'PUBLIC'"}
+	var args []interface{}
+
+	if s.From != nil && s.From.After(time.Now()) {
+		conds = append(conds, "e.date >= ?")
+		args = append(args, *s.From)
+	} else {
+		conds = append(conds, "e.date >= NOW()")
+	}
+	if s.To != nil {
+		conds = append(conds, "e.date <= ?")
+		args = append(args, *s.To)
+	}
+	if s.terms != "" {
+		conds = append(conds, eventMatch)
+		args = append(args, s.terms)
+	}
+	if s.City != "" {
+		conds = append(conds, "e.city = ?")
+		args = append(args, s.City)
+	}
+	if s.State != "" {
+		conds = append(conds, "e.state = ?")
+		args = append(args, s.State)
+	}
+	if s.Country != "" {
+		conds = append(conds, "e.country = ?")
+		args = append(args, s.Country)
+	}
+	if s.OrgID != 0 {
+		conds = append(conds, "e.org_id = ?")
+		args = append(args, s.OrgID)
+	}
+	if s.Category != "" {
+		conds = append(conds, "e.id IN ("+taggedEvents+")")
+		args = append(args, "CATEGORY", s.Category)
+	}
+	if s.Tag != "" {
+		conds = append(conds, "e.id IN ("+taggedEvents+")")
+		args = append(args, "TAG", s.Tag)
+	}
+	if s.Available {
+		conds = append(conds, "e.seats_available > 0")
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// cacheHash identifies the page asked for, used in the redis key
+func (s *eventSearch) cacheHash() string {
+	key, _ := json.Marshal(s)
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:16])
+}
+
+// searchEventsHandler is full-text search over upcoming public events with
+// filters, facets & cursor pagination, pages are cached in redis under the
+// event version like the listing
+func (h *Handler) searchEventsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+	defer cancel()
+
+	s, err := parseEventSearch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var version int
+	hash := s.cacheHash()
+	if h.redisClient != nil {
+		v, err := h.redisClient.GetEventVerison(ctx)
+		if err != nil || v == -1 {
+			log.Printf("version error on search: %v", err)
+		}
+		version = v
+
+		page, err := h.redisClient.GetSearchCache(ctx, version, hash)
+		if err != nil {
+			log.Printf("failed to read search cache: %v", err)
+		}
+		if page != nil {
+			h.writeSearchPage(c, "search results from cache", page)
+			return
+		}
+	}
+
+	page, err := h.runEventSearch(ctx, s)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusRequestTimeout, gin.H{
+				"error": "query timeout",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.redisClient != nil && version != -1 {
+		if err := h.redisClient.SetSearchCache(ctx, version, hash, *page); err != nil {
+			log.Printf("failed to set search cache: %v", err)
+		}
+	}
+
+	h.writeSearchPage(c, "search results", page)
+}
+
+func (h *Handler) runEventSearch(ctx context.Context, s *eventSearch) (*models.EventSearchCache, error) {
+	where, whereArgs := s.where()
+
+	var args []interface{}
+	score := "0"
+	if s.terms != "" {
+		score = eventMatch
+		args = append(args, s.terms)
+	}
+	args = append(args, whereArgs...)
+
+	// keyset on the sort order, ids break ties
+	order := "e.date ASC, e.id ASC"
+	if s.cursor != nil {
+		if s.Sort == "relevance" {
+			where += " AND (" + eventMatch + " < ? OR (" + eventMatch + " = ? AND e.id > ?))"
+			args = append(args, s.terms, s.cursor.Score, s.terms, s.cursor.Score, s.cursor.ID)
+		} else {
+			where += " AND (e.date > ? OR (e.date = ? AND e.id > ?))"
+			args = append(args, s.cursor.Date, s.cursor.Date, s.cursor.ID)
+		}
+	}
+	if s.Sort == "relevance" {
+		order = "score DESC, e.id ASC"
+	}
+	args = append(args, s.Limit+1)
+
+	query := "SELECT e.id, e.name, e.org_id, e.organized_by, e.image_key, e.date, e.city, " + score + " AS score FROM event e WHERE " + where + " ORDER BY " + order + " LIMIT ?"
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.EventSearchCache{Events: make([]models.EventCache, 0, s.Limit+1)}
+	var scores []float64
+	for rows.Next() {
+		var e models.EventCache
+		var sc float64
+		if err := rows.Scan(&e.EventID, &e.EventName, &e.OrganizationID, &e.OrganizationName, &e.EventKey, &e.EventDate, &e.City, &sc); err != nil {
+			return nil, err
+		}
+		page.Events = append(page.Events, e)
+		scores = append(scores, sc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > s.Limit {
+		page.HasNext = true
+		page.Events = page.Events[:s.Limit]
+		last := page.Events[s.Limit-1]
+		cur, _ := json.Marshal(models.SearchCursor{Date: last.EventDate, Score: scores[s.Limit-1], ID: int64(last.EventID)})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(cur)
+	}
+
+	ids := make([]int, len(page.Events))
+	for i, e := range page.Events {
+		ids[i] = e.EventID
+	}
+	tags, err := loadEventTags(ctx, h.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Events {
+		page.Events[i].EventTags = tags[page.Events[i].EventID]
+	}
+
+	// facets describe the whole result set, the first page carries them
+	if s.cursor == nil {
+		where, whereArgs := s.where()
+		facets := &models.SearchFacets{}
+
+		facets.Cities, err = searchFacet(ctx, h.db, "SELECT e.city, COUNT(*) FROM event e WHERE "+where+" GROUP BY e.city ORDER BY COUNT(*) DESC, e.city LIMIT 20", whereArgs)
+		if err != nil {
+			return nil, err
+		}
+		facets.Categories, err = searchFacet(ctx, h.db, "SELECT t.name, COUNT(*) FROM event e JOIN event_tag et ON et.event_id = e.id JOIN tag t ON t.id = et.tag_id AND t.kind = 'CATEGORY' WHERE "+where+" GROUP BY t.name ORDER BY COUNT(*) DESC, t.name", whereArgs)
+		if err != nil {
+			return nil, err
+		}
+		facets.Months, err = searchFacet(ctx, h.db, "SELECT DATE_FORMAT(e.date, '%Y-%m') AS month, COUNT(*) FROM event e WHERE "+where+" GROUP BY month ORDER BY month", whereArgs)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range facets.Months {
+			facets.Total += m.Count
+		}
+		page.Facets = facets
+	}
+
+	return page, nil
+}
+
+func searchFacet(ctx context.Context, db *sql.DB, query string, args []interface{}) ([]models.SearchFacet, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facet := make([]models.SearchFacet, 0)
+	for rows.Next() {
+		var f models.SearchFacet
+		if err := rows.Scan(&f.Value, &f.Count); err != nil {
+			return nil, err
+		}
+		facet = append(facet, f)
+	}
+	return facet, rows.Err()
+}
+
+// writeSearchPage adds the image urls (not cached, they expire) & responds
+func (h *Handler) writeSearchPage(c *gin.Context, message string, page *models.EventSearchCache) {
+	events := make([]models.EventResponse, 0, len(page.Events))
+	for _, e := range page.Events {
+		res := newEventResponse(e.EventID, e.EventName, e.EventDate, h.generateImageUrl(e.EventKey), e.OrganizationID, e.OrganizationName, e.City)
+		res.EventTags = e.EventTags
+		events = append(events, *res)
+	}
+
+	resp := gin.H{
+		"message":     message,
+		"data":        events,
+		"has_next":    page.HasNext,
+		"next_cursor": page.NextCursor,
+	}
+	if page.Facets != nil {
+		resp["facets"] = page.Facets
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// getEventByCityHandler lists the upcoming public events of a city
+func (h *Handler) getEventByCityHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+	defer cancel()
+
+	city := strings.TrimSpace(c.Param("city"))
+
+	// city compares case-insensitively under the default collation
+	q := "SELECT id, name, org_id, organized_by, image_key, date, city FROM event WHERE city = ? AND visible = 'PUBLIC' AND date >= NOW() ORDER BY date ASC LIMIT 50"
+	rows, err := h.db.QueryContext(ctx, q, city)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	events := make([]models.EventResponse, 0)
+	for rows.Next() {
+		var e models.Event
+		if err := rows.Scan(&e.Id, &e.Name, &e.OrgId, &e.OrganizedBy, &e.Key, &e.Date, &e.City); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "row scan error:" + err.Error(),
+			})
+			return
+		}
+		events = append(events, *newEventResponse(int(e.Id), e.Name, e.Date, h.generateImageUrl(e.Key), int(e.OrgId), e.OrganizedBy, e.City))
+	}
+	h.withTags(ctx, events)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "retrived events by city",
+		"data":    events,
+	})
+}
+
+// nearbyEventsHandler lists upcoming public events within radius_km of a
+// coordinate, nearest first. The bounding box narrows rows down on
+// idx_geo before the exact distance is computed.
+func (h *Handler) nearbyEventsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+	defer cancel()
+
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required numbers"})
+		return
+	}
+	p := geo.Point{Lat: lat, Lng: lng}
+	if err := p.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	radius := 10.0
+	if v := c.Query("radius_km"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r <= 0 || r > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be within (0, 200]"})
+			return
+		}
+		radius = r
+	}
+
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be within [1, 50]"})
+			return
+		}
+		limit = n
+	}
+
+	box := geo.BoundingBox(p, radius)
+	lngCond := "longitude BETWEEN ? AND ?"
+	if box.MinLng > box.MaxLng {
+		lngCond = "(longitude >= ? OR longitude <= ?)"
+	}
+
+	query := `SELECT id, name, org_id, organized_by, image_key, date, city,
+			ST_Distance_Sphere(POINT(longitude, latitude), POINT(?, ?)) / 1000 AS distance_km
+		FROM event
+		WHERE latitude BETWEEN ? AND ? AND ` + lngCond + ` AND visible = 'PUBLIC' AND date >= NOW()
+		HAVING distance_km <= ?
+		ORDER BY distance_km, date LIMIT ?`
+	rows, err := h.db.QueryContext(ctx, query, p.Lng, p.Lat, box.MinLat, box.MaxLat, box.MinLng, box.MaxLng, radius, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	events := make([]models.EventResponse, 0)
+	for rows.Next() {
+		var e models.Event
+		var distance float64
+		if err := rows.Scan(&e.Id, &e.Name, &e.OrgId, &e.OrganizedBy, &e.Key, &e.Date, &e.City, &distance); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "row scan error:" + err.Error(),
+			})
+			return
+		}
+		res := newEventResponse(int(e.Id), e.Name, e.Date, h.generateImageUrl(e.Key), int(e.OrgId), e.OrganizedBy, e.City)
+		distance = math.Round(distance*100) / 100
+		res.DistanceKm = &distance
+		events = append(events, *res)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.withTags(ctx, events)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "retrived events near you",
+		"data":    events,
+	})
+}
+
+// pointOf checks coordinates sent by an organizer, nil when none were sent
+func pointOf(lat, lng *float64) (*geo.Point, error) {
+	if lat == nil && lng == nil {
+		return nil, nil
+	}
+	if lat == nil || lng == nil {
+		return nil, errors.New("latitude and longitude go together")
+	}
+	p := geo.Point{Lat: *lat, Lng: *lng}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// geocode returns the coordinates of addr, nil when geocoding is off or
+// fails, an event without coordinates just doesn't show up near anyone
+func (h *Handler) geocode(ctx context.Context, addr geo.Address) *geo.Point {
+	if h.geocoder == nil {
+		return nil
+	}
+	p, err := h.geocoder.Geocode(ctx, addr)
+	if err != nil {
+		if !errors.Is(err, geo.ErrNotFound) {
+			log.Printf("failed to geocode %s, %s: %v", addr.City, addr.Country, err)
+		}
+		return nil
+	}
+	return p
+}
+
+// geocodeEvent sets the coordinates of an event whose address changed,
+// skipped if the address changed again or coordinates were set meanwhile
+func (h *Handler) geocodeEvent(ctx context.Context, eventID int64, addr geo.Address) {
+	p := h.geocode(ctx, addr)
+	if p == nil {
+		return
+	}
+	_, err := h.db.ExecContext(ctx, `UPDATE event SET latitude = ?, longitude = ?
+		WHERE id = ? AND address = ? AND city = ? AND state = ? AND country = ? AND latitude IS NULL`,
+		p.Lat, p.Lng, eventID, addr.Street, addr.City, addr.State, addr.Country)
+	if err != nil {
+		log.Printf("failed to set coordinates of event %d: %v", eventID, err)
+	}
+}
+
+func (h *Handler) getUpcomingEventCityHandler(c *gin.Context) {
+	city := c.Query("city")
+	exclude := c.Query("exclude")
+	excludeId, err := strconv.Atoi(exclude)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("wrong event id(%s) type, should be int", exclude),
+		})
+		return
+	}
+
+	query := "SELECT id, name, org_id, organized_by, capacity, seats_available, date, address, city, state, country, created_at, image_key, visible FROM event WHERE city = ? AND visible = 'PUBLIC' AND id != ? ORDER BY date ASC LIMIT 6"
+	rows, err := h.db.Query(query, city, excludeId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var eventRes []models.EventResponse
+	for rows.Next() {
+		var e models.Event
+		if err := rows.Scan(&e.Id, &e.Name, &e.OrgId, &e.OrganizedBy, &e.Capacity, &e.SeatsAvailable, &e.Date, &e.Address, &e.City, &e.State, &e.Country, &e.CreatedAt, &e.Key, &e.Visible); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "row scan error:" + err.Error(),
+			})
+			return
+		}
+		imageUrl := h.generateImageUrl(e.Key)
+		eventRes = append(eventRes, *newEventResponse(int(e.Id), e.Name, e.Date, imageUrl, int(e.OrgId), e.OrganizedBy, e.City))
+	}
+
+	if len(eventRes) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("No upcoming events found for city: %v", city),
+			"data":    []interface{}{},
+		})
+		return
+	}
+	h.withTags(c.Request.Context(), eventRes)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("served upcoming events by city excluding (%v)", exclude),
+		"data":    eventRes,
+	})
+
+}
+
+func (h *Handler) getSeatsAvailabilityByEvent(c *gin.Context) {
+	i := c.Param("id")
+	id, err := strconv.Atoi(i)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	query := "SELECT seats_available FROM event WHERE id = ?"
+	var seats int64
+	if err := h.db.QueryRow(query, id).Scan(&seats); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// seats on hold are not bookable by others
+	if h.redisClient != nil {
+		held, err := h.redisClient.GetHeldSeats(c.Request.Context(), int64(id))
+		if err != nil {
+			log.Printf("failed to read seat holds for event %d: %v", id, err)
+		}
+		seats -= held
+		if seats < 0 {
+			seats = 0
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "seats retrived!",
+		"data":    seats,
+	})
+}
+
+// for user & org -- in use
+func (h *Handler) getEventByIdHandler(c *gin.Context) {
+	strId := c.Param("id")
+	if strId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "event ID is required",
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(strId) //into int (from string)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	query := "SELECT id, name, description, org_id, organized_by, image_key, capacity, seats_available, date, address, city, state, country, latitude, longitude, created_at, visible, max_seats_per_booking, max_seats_per_user, max_bookings_per_user FROM event WHERE id = ?"
+	row := h.db.QueryRow(query, id)
+	var event models.Event
+	if err := row.Scan(&event.Id, &event.Name, &event.Description, &event.OrgId, &event.OrganizedBy, &event.Key, &event.Capacity, &event.SeatsAvailable, &event.Date, &event.Address, &event.City, &event.State, &event.Country, &event.Latitude, &event.Longitude, &event.CreatedAt, &event.Visible, &event.MaxSeatsPerBooking, &event.MaxSeatsPerUser, &event.MaxBookingsPerUser); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "row scan error:" + err.Error(),
+		})
+		return
+	}
+
+	tags, err := loadEventTags(c.Request.Context(), h.db, []int{int(event.Id)})
+	if err != nil {
+		log.Printf("failed to load tags of event %d: %v", event.Id, err)
+	}
+	event.EventTags = tags[int(event.Id)]
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("found event by id:%v", event.Id),
+		"data":    event,
+	})
+
+}
+
+// used for event image upload
+func (h *Handler) getPresignedUrl(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	// get org info thrugh org-middleware
+	currOrg, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	org := currOrg.(models.Organization)
+
+	var req struct {
+		FileName string `json:"fileName"`
+		FileType string `json:"fileType"`
+	}
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	// key -> /events/uploads/1/demo.png
+	key, err := blob.EventImageKey(org.Id, req.FileName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file name"})
+		return
+	}
+
+	url, err := h.blobs.UploadURL(ctx, key, 10*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("failed to generate presigned url:%s", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uploadUrl":  url,
+		"presignKey": key,
+	})
+}
+
+// get image url from cloudfront when given image key
+// for user -- in use (per event image url generation)
+func (h *Handler) getImageUrlPerEvent(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "image key is required",
+		})
+		return
+	}
+
+	url := h.generateImageUrl(key)
+	c.JSON(http.StatusOK, gin.H{
+		"imageUrl": url,
+	})
+}
+
+// getUserDetailHandler retrives the user email, name etc
+// gets user details from middleware
+func (h *Handler) getUserDetailHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "user doesn't exists or timeout",
+		})
+		return
+	}
+
+	u := user.(models.User)
+
+	var userDetail models.User
+	query := "SELECT first_name, last_name, email, created_at FROM user WHERE id = ?"
+	if err := h.db.QueryRowContext(ctx, query, u.Id).Scan(&userDetail.FirstName, &userDetail.LastName, &userDetail.Email, &userDetail.CreatedAt); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusRequestTimeout, gin.H{
+				"error": "query timeout",
+			})
+			return
+		}
+
+		if errors.Is(err, context.Canceled) {
+			c.JSON(499, gin.H{
+				"error": "request canceled by client",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to fetch user detail" + err.Error(),
+		})
+		return
+	}
+	userDetail.Id = u.Id
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("user detail retrived for user with id %d", u.Id),
+		"data":    userDetail,
+	})
+}
+
+// getUserBooking is used to fetch all bookings for the
+// user with event details, user detail is provided by middleware
+func (h *Handler) getUserBookings(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	query := `SELECT  b.id, b.event_id, b.seats, b.status, b.booked_at, COALESCE(t.name, ''), b.amount, b.currency, e.name, e.date, e.city FROM booking b JOIN event e ON b.event_id = e.id LEFT JOIN ticket_tier t ON t.id = b.tier_id WHERE b.user_id = ? ORDER BY b.booked_at DESC`
+
+	rows, err := h.db.QueryContext(ctx, query, u.Id)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{
+				"error": "request timeout or canceled",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to query bookings",
+		})
+		return
+	}
+	defer rows.Close()
+
+	var bookings []models.UserBookings
+	for rows.Next() {
+		var booking models.UserBookings
+		err := rows.Scan(&booking.Id, &booking.EventId, &booking.Seats, &booking.Status, &booking.BookedAt, &booking.TierName, &booking.Amount, &booking.Currency, &booking.EventName, &booking.Date, &booking.City)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to scan booking row",
+			})
+			return
+		}
+
+		booking.UserId = u.Id
+		bookings = append(bookings, booking)
+	}
+
+	if err = rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "error iterating bookings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "bookings retrieved successfully",
+		"data":    bookings,
+		"count":   len(bookings),
+	})
+}
+
+// getPDFPresignedURL handler returns booked ticket pdf
+// from s3 service to client, having param as bookingID
+func (h *Handler) getPDFPresignedURL(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	bId := c.Param("booking_id")
+	bookingID, err := strconv.Atoi(bId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "booking ID is required || inviald booking ID format!",
+		})
+		return
+	}
+
+	keyName := blob.ReceiptKey(int(u.Id), bookingID)
+
+	url, err := h.blobs.DownloadURL(ctx, keyName, 10*time.Minute)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{
+				"error": "request timeout or canceled",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to query bookings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("presigned url genrated for bookingId:%d", bookingID),
+		"data":    url,
+	})
+}
+
+// seatBookingHandler is to booking seats for client &
+// provides payload to NATS server for pdf genration
+// and email notification
+func (h *Handler) seatBookingHandler(c *gin.Context) {
+	// create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	// fetching user details through middleware
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	// event_id from the params
+	stringId := c.Param("event_id")
+	eventId, err := strconv.Atoi(stringId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return;
+	}
+
+	// booking details recived as body from user i.e number of seats
+	var b models.BookingRequest
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "json binding error:" + err.Error(),
+		})
+		return
+	}
+
+	// if user selected seats are less than zero (wrong value)
+	if b.Seats <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seats must be > 0"})
+		return
+	}
+
+	// retried request with the same Idempotency-Key gets the stored response
+	idemKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	var fingerprint string
+	if idemKey != "" {
+		if len(idemKey) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+		fingerprint = requestFingerprint(eventId, b)
+		if h.replayIdempotentResponse(ctx, c, u.Id, idemKey, fingerprint) {
+			return
+		}
+	}
+
+	// hot events let users in through the waiting room only
+	if !h.checkAdmission(ctx, c, eventId, u.Id) {
+		return
+	}
+
+	// begain transactional query
+	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "begainTx err:" + err.Error(),
+		})
+		return
+	}
+	// incase if any failure in between -> rollback
+	defer tx.Rollback()
+
+	// lock event row, check seats (minus active holds) & insert booking
+	booked, err := h.bookSeatsTx(ctx, tx, seatRequest{
+		EventID: eventId,
+		UserID:  u.Id,
+		Seats:   b.Seats,
+		TierID:  b.TierID,
+	})
+	if err != nil {
+		resp := gin.H{"error": err.Error()}
+		// sold out, point the client to the waitlist
+		if errors.Is(err, errNotEnoughSeats) {
+			resp["waitlist_url"] = fmt.Sprintf("/api/waitlist/%d", eventId)
+		}
+		c.JSON(bookingErrorStatus(err), resp)
+		return
+	}
+
+	bookingID := booked.BookingID
+	message := "seat booked successfully"
+	if booked.Status == "PENDING_PAYMENT" {
+		message = "seats reserved, complete the payment to confirm the booking"
+	}
+	resp := gin.H{
+		"message": message,
+		"data": gin.H{
+			"booking_id":         bookingID,
+			"event_id":           eventId,
+			"user_id":            u.Id,
+			"seats":              b.Seats,
+			"tier_id":            b.TierID,
+			"tier_name":          booked.TierName,
+			"amount":             booked.Amount,
+			"currency":           booked.Currency,
+			"status":             booked.Status,
+			"payment_expires_at": booked.PaymentExpiresAt,
+		},
+	}
+
+	// key is stored in the same transaction as the booking, so a booking
+	// can never exist without its stored response
+	if idemKey != "" {
+		if err := saveIdempotentResponse(ctx, tx, u.Id, idemKey, fingerprint, http.StatusOK, resp); err != nil {
+			// concurrent request with the same key committed first
+			if isDuplicateKeyErr(err) {
+				tx.Rollback()
+				if h.replayIdempotentResponse(ctx, c, u.Id, idemKey, fingerprint) {
+					return
+				}
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to store idempotency key: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	// ticket is sent once the booking is paid for
+	if booked.Status == "CONFIRMED" {
+		pdfCont := newPdfContent(int(bookingID), int(u.Id), u.FirstName, u.Email, b.EventName, b.DateTime, int(b.Seats))
+		pdfCont.TierName = booked.TierName
+		pdfCont.Amount = booked.Amount
+		pdfCont.Currency = booked.Currency
+		setTicketToken(pdfCont, int64(eventId))
+
+		if err := addBookingMessage(ctx, tx, bus.BookingMsgID(int(bookingID)), pdfCont); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue ticket: " + err.Error()})
+			return
+		}
+		if err := reminder.Schedule(ctx, tx, bookingID, int64(eventId)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// requestFingerprint hashes the booking request, used to detect an
+// Idempotency-Key being reused for a different request
+func requestFingerprint(eventId int, b models.BookingRequest) string {
+	body, _ := json.Marshal(b)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", eventId, body)))
+	return hex.EncodeToString(sum[:])
+}
+
+// replayIdempotentResponse writes the stored response when the key was
+// already used by the user, returns true if the request got answered
+func (h *Handler) replayIdempotentResponse(ctx context.Context, c *gin.Context, userId int64, key, fingerprint string) bool {
+	var rec models.IdempotencyRecord
+	query := "SELECT request_hash, status_code, response FROM idempotency_key WHERE user_id = ? AND idem_key = ?"
+	err := h.db.QueryRowContext(ctx, query, userId, key).Scan(&rec.RequestHash, &rec.StatusCode, &rec.Response)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to read idempotency key: " + err.Error(),
+		})
+		return true
+	}
+
+	if rec.RequestHash != fingerprint {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Idempotency-Key was already used with a different request",
+		})
+		return true
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(rec.StatusCode, "application/json; charset=utf-8", rec.Response)
+	return true
+}
+
+// saveIdempotentResponse stores the response for the key inside tx
+func saveIdempotentResponse(ctx context.Context, tx *sql.Tx, userId int64, key, fingerprint string, status int, resp gin.H) error {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO idempotency_key (user_id, idem_key, request_hash, status_code, response) VALUES (?, ?, ?, ?, ?)"
+	_, err = tx.ExecContext(ctx, query, userId, key, fingerprint, status, body)
+	return err
+}
+
+// mysql error 1062 i.e unique key violation
+func isDuplicateKeyErr(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// seatRequest is what bookSeatsTx is asked to book
+type seatRequest struct {
+	EventID int
+	UserID  int64
+	Seats   int64
+	TierID  int64  // 0 for events without tiers
+	HoldID  string // caller's own hold when confirming one
+}
+
+// seatBooking is what bookSeatsTx booked
+type seatBooking struct {
+	BookingID int64
+	TierName  string
+	Amount    int64
+	Currency  string
+	// PENDING_PAYMENT until the gateway confirms paid bookings
+	Status           string
+	PaymentExpiresAt *time.Time
+}
+
+// seatsFree is what is left for a booking or hold under the row locks
+type seatsFree struct {
+	event  int64
+	tier   *models.TicketTier // nil for events without tiers
+	inTier int64
+
+	// what the user already has on the event, bookings + holds
+	limits       models.BookingLimits
+	userSeats    int64
+	userBookings int64
+}
+
+// fits reports if n seats fit in the event and the tier
+func (f *seatsFree) fits(n int64) bool {
+	if f.event < n {
+		return false
+	}
+	return f.tier == nil || f.inTier >= n
+}
+
+// withinLimits checks n more seats against the organizer's per user caps
+func (f *seatsFree) withinLimits(n int64) error {
+	return f.limits.Check(n, f.userSeats, f.userBookings)
+}
+
+// lockSeatsTx locks the event row (and the tier row when booking a tier)
+// and returns the seats still free once active holds are taken out.
+// holdID is the caller's own hold (if any), it must still be active and
+// its seats are not counted against the caller.
+func (h *Handler) lockSeatsTx(ctx context.Context, tx *sql.Tx, eventId int, tierID int64, userId int64, holdID string) (*seatsFree, error) {
+	// check if we do have enough seats_available
+	var seatsAvailable int64
+	var visible string
+	var limits models.BookingLimits
+	err := tx.QueryRowContext(
+		ctx,
+		"SELECT seats_available, visible, max_seats_per_booking, max_seats_per_user, max_bookings_per_user FROM event WHERE id = ? FOR UPDATE",
+		eventId,
+	).Scan(&seatsAvailable, &visible, &limits.MaxSeatsPerBooking, &limits.MaxSeatsPerUser, &limits.MaxBookingsPerUser)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errEventNotFound
+		}
+		return nil, err
+	}
+	switch visible {
+	case "DELETED":
+		return nil, errEventNotFound
+	case "CANCELLED":
+		return nil, errEventCancelled
+	}
+
+	tier, err := lockTierTx(ctx, tx, eventId, tierID)
+	if err != nil {
+		return nil, err
+	}
+
+	free := &seatsFree{event: seatsAvailable, tier: tier, limits: limits}
+	if tier != nil {
+		free.inTier = tier.SeatsAvailable
+	}
+
+	// the event row lock serializes requests of the same user too, so the
+	// count can't go stale before the booking is inserted
+	if limits.MaxSeatsPerUser != nil || limits.MaxBookingsPerUser != nil {
+		query := "SELECT COUNT(*), COALESCE(SUM(seats), 0) FROM booking WHERE event_id = ? AND user_id = ? AND status IN ('CONFIRMED', 'PENDING_PAYMENT')"
+		if err := tx.QueryRowContext(ctx, query, eventId, userId).Scan(&free.userBookings, &free.userSeats); err != nil {
+			return nil, err
+		}
+	}
+
+	// seats held by others are not available to this user
+	if h.redisClient != nil {
+		holds, err := h.redisClient.GetActiveHolds(ctx, int64(eventId))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seat holds: %w", err)
+		}
+
+		// other holds of the user count towards the user limits
+		heldSeats, heldCount := models.HeldByUser(holds, userId, holdID)
+		free.userSeats += heldSeats
+		free.userBookings += heldCount
+
+		found := false
+		for _, hold := range holds {
+			if hold.HoldID == holdID && hold.UserID == userId {
+				found = true
+				continue
+			}
+			free.event -= hold.Seats
+			if tier != nil && hold.TierID == tier.Id {
+				free.inTier -= hold.Seats
+			}
+		}
+
+		if holdID != "" && !found {
+			return nil, errHoldNotFound
+		}
+	}
+
+	return free, nil
+}
+
+// lockTierTx locks the tier being booked, events with tiers must be
+// booked against one of them and only while its sale window is open
+func lockTierTx(ctx context.Context, tx *sql.Tx, eventId int, tierID int64) (*models.TicketTier, error) {
+	if tierID == 0 {
+		var hasTiers bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM ticket_tier WHERE event_id = ?)", eventId).Scan(&hasTiers); err != nil {
+			return nil, err
+		}
+		if hasTiers {
+			return nil, errTierRequired
+		}
+		return nil, nil
+	}
+
+	var t models.TicketTier
+	query := "SELECT id, event_id, name, price, currency, capacity, seats_available, sale_starts_at, sale_ends_at FROM ticket_tier WHERE id = ? AND event_id = ? FOR UPDATE"
+	err := tx.QueryRowContext(ctx, query, tierID, eventId).Scan(&t.Id, &t.EventId, &t.Name, &t.Price, &t.Currency, &t.Capacity, &t.SeatsAvailable, &t.SaleStartsAt, &t.SaleEndsAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errTierNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if (t.SaleStartsAt != nil && now.Before(*t.SaleStartsAt)) || (t.SaleEndsAt != nil && now.After(*t.SaleEndsAt)) {
+		return nil, errTierNotOnSale
+	}
+
+	return &t, nil
+}
+
+// bookSeatsTx checks that the seats asked for are still free under the
+// row locks, takes them from the event (and tier) and inserts the booking
+func (h *Handler) bookSeatsTx(ctx context.Context, tx *sql.Tx, req seatRequest) (*seatBooking, error) {
+	free, err := h.lockSeatsTx(ctx, tx, req.EventID, req.TierID, req.UserID, req.HoldID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := free.withinLimits(req.Seats); err != nil {
+		return nil, err
+	}
+
+	// if seats are available as per user demand
+	if !free.fits(req.Seats) {
+		return nil, errNotEnoughSeats
+	}
+
+	// update the event seats_available
+	query := "UPDATE event SET seats_available = seats_available - ? WHERE id = ?"
+	result, err := tx.ExecContext(ctx, query, req.Seats, req.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, errNotEnoughSeats
+	}
+
+	booked := &seatBooking{Currency: defaultCurrency, Status: "CONFIRMED"}
+	tierID := sql.NullInt64{}
+	if free.tier != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE ticket_tier SET seats_available = seats_available - ? WHERE id = ?", req.Seats, free.tier.Id); err != nil {
+			return nil, err
+		}
+		tierID = sql.NullInt64{Int64: free.tier.Id, Valid: true}
+		booked.TierName = free.tier.Name
+		booked.Amount = free.tier.Price * req.Seats
+		booked.Currency = free.tier.Currency
+	}
+
+	// paid bookings hold their seats only until the payment deadline
+	if booked.Amount > 0 {
+		expiresAt := time.Now().Add(paymentTTL)
+		booked.Status = "PENDING_PAYMENT"
+		booked.PaymentExpiresAt = &expiresAt
+	}
+
+	// the hold is only released from redis after commit, its unique
+	// hold_id keeps a concurrent confirm of it from booking twice
+	holdID := sql.NullString{String: req.HoldID, Valid: req.HoldID != ""}
+
+	//  insert record in booking table
+	query = "INSERT INTO booking (event_id, user_id, seats, tier_id, amount, currency, status, payment_expires_at, hold_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := tx.ExecContext(ctx, query, req.EventID, req.UserID, req.Seats, tierID, booked.Amount, booked.Currency, booked.Status, booked.PaymentExpiresAt, holdID)
+	if err != nil {
+		if holdID.Valid && isDuplicateKeyErr(err) {
+			return nil, errHoldConfirmed
+		}
+		return nil, err
+	}
+
+	booked.BookingID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return booked, nil
+}
+
+// bookingErrorStatus maps errors from the booking flow to http status
+func bookingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errEventNotFound), errors.Is(err, errHoldNotFound), errors.Is(err, errTierNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNotEnoughSeats), errors.Is(err, errTierNotOnSale), errors.Is(err, errEventCancelled), errors.Is(err, errHoldConfirmed):
+		return http.StatusConflict
+	case errors.Is(err, errTierRequired), errors.Is(err, errSeatsPerBooking):
+		return http.StatusBadRequest
+	case errors.Is(err, errUserLimit):
+		return http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusRequestTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// createSeatHoldHandler places a short lived hold on seats so the user
+// can fill in details before booking, the hold is kept in redis and
+// expires by itself after seatHoldTTL
+func (h *Handler) createSeatHoldHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	eventId, err := strconv.Atoi(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	var req models.HoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "json binding error:" + err.Error(),
+		})
+		return
+	}
+
+	if req.Seats <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seats must be > 0"})
+		return
+	}
+
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "seat holds are not available right now",
+		})
+		return
+	}
+
+	if !h.checkAdmission(ctx, c, eventId, u.Id) {
+		return
+	}
+
+	// lock the event row so concurrent holds can't over commit seats
+	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "begainTx err:" + err.Error(),
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	free, err := h.lockSeatsTx(ctx, tx, eventId, req.TierID, u.Id, "")
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := free.withinLimits(req.Seats); err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if !free.fits(req.Seats) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "not enough seats available",
+		})
+		return
+	}
+
+	hold := models.SeatHold{
+		HoldID:    randomID(),
+		EventID:   int64(eventId),
+		UserID:    u.Id,
+		TierID:    req.TierID,
+		Seats:     req.Seats,
+		ExpiresAt: time.Now().Add(seatHoldTTL),
+	}
+	if err := h.redisClient.CreateSeatHold(ctx, hold); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to hold seats: " + err.Error(),
+		})
+		return
+	}
+
+	// nothing written in mysql, commit only releases the row lock
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("%d seats held for %v", hold.Seats, seatHoldTTL),
+		"data":    hold,
+	})
+}
+
+// asyncBookingHandler queues a booking request on NATS instead of booking
+// right away, meant for flash sales where locking the event row per http
+// request doesn't keep up. The client polls the returned request id.
+func (h *Handler) asyncBookingHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	eventId, err := strconv.Atoi(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	var b models.BookingRequest
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "json binding error:" + err.Error(),
+		})
+		return
+	}
+	if b.Seats <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seats must be > 0"})
+		return
+	}
+
+	// outcomes are kept in redis, nothing to poll without it
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "async booking is not available right now",
+		})
+		return
+	}
+
+	if !h.checkAdmission(ctx, c, eventId, u.Id) {
+		return
+	}
+
+	// same Idempotency-Key -> same request id, a retry polls the first one
+	requestID := randomID()
+	if idemKey := strings.TrimSpace(c.GetHeader("Idempotency-Key")); idemKey != "" {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%s", u.Id, eventId, idemKey)))
+		requestID = hex.EncodeToString(sum[:16])
+
+		existing, err := h.redisClient.GetBookingRequestStatus(ctx, requestID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusAccepted, gin.H{
+				"message": "booking request already queued",
+				"data":    existing,
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	req := models.AsyncBookingRequest{
+		RequestID: requestID,
+		EventID:   int64(eventId),
+		UserID:    u.Id,
+		UserName:  u.FirstName,
+		UserEmail: u.Email,
+		Seats:     b.Seats,
+		TierID:    b.TierID,
+		QueuedAt:  now,
+	}
+	status := models.AsyncBookingStatus{
+		RequestID: requestID,
+		EventID:   int64(eventId),
+		UserID:    u.Id,
+		Status:    models.AsyncBookingQueued,
+		UpdatedAt: now,
+	}
+
+	// status first, the allocator may finish before we return
+	if err := h.redisClient.SetBookingRequestStatus(ctx, status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to queue booking request: " + err.Error(),
+		})
+		return
+	}
+
+	p, _ := json.Marshal(req)
+	if err := h.msgBus.Publish(ctx, bus.SubjectBookingRequest, bus.BookingRequestMsgID(requestID), p); err != nil {
+		status.Status = models.AsyncBookingRejected
+		status.Reason = "failed to queue booking request"
+		status.UpdatedAt = time.Now()
+		if err := h.redisClient.SetBookingRequestStatus(ctx, status); err != nil {
+			log.Printf("failed to store booking request %s status: %v", requestID, err)
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "failed to queue booking request: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "booking request queued",
+		"data": gin.H{
+			"request_id": requestID,
+			"status":     status.Status,
+			"status_url": "/api/booking-requests/" + requestID,
+		},
+	})
+}
+
+// getAsyncBookingStatusHandler returns the outcome of a queued booking request
+func (h *Handler) getAsyncBookingStatusHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "async booking is not available right now",
+		})
+		return
+	}
+
+	status, err := h.redisClient.GetBookingRequestStatus(ctx, c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status == nil || status.UserID != u.Id {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking request not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "booking request status",
+		"data":    status,
+	})
+}
+
+// allocateBookings books a batch of queued requests, requests of the same
+// event are allocated in arrival order under one lock of the event row
+func (h *Handler) allocateBookings(ctx context.Context, reqs []models.AsyncBookingRequest) error {
+	byEvent := make(map[int64][]models.AsyncBookingRequest)
+	var eventIds []int64
+	for _, r := range reqs {
+		if _, ok := byEvent[r.EventID]; !ok {
+			eventIds = append(eventIds, r.EventID)
+		}
+		byEvent[r.EventID] = append(byEvent[r.EventID], r)
+	}
+
+	for _, eventId := range eventIds {
+		if err := h.allocateEventBookings(ctx, eventId, byEvent[eventId]); err != nil {
+			return fmt.Errorf("event %d: %w", eventId, err)
+		}
+	}
+	return nil
+}
+
+// runAllocator is the main of the booking allocator, a single process
+// consumes the queued requests so those of an event are booked in arrival
+// order. The api replicas only queue them.
+func (h *Handler) runAllocator(natsIns *nats.NATSIns) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if natsIns == nil {
+		log.Fatal("the booking allocator needs MESSAGE_BUS=nats")
+	}
+	if err := natsIns.CreateAllocationConsumer(ctx, allocationBatchSize); err != nil {
+		log.Fatal("consumer creation failed:", err)
+	}
+
+	log.Println("Booking allocator started")
+	if err := natsIns.ConsumeBookingRequests(ctx, allocationBatchSize, h.allocateBookings, h.rejectBookingRequest); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// rejectBookingRequest answers the status poll of a request the allocator
+// gave up on, it was dead-lettered after failing every attempt
+func (h *Handler) rejectBookingRequest(ctx context.Context, req models.AsyncBookingRequest, err error) {
+	log.Printf("booking request %s rejected after failed allocations: %v", req.RequestID, err)
+	st := models.AsyncBookingStatus{
+		RequestID: req.RequestID,
+		EventID:   req.EventID,
+		UserID:    req.UserID,
+		Status:    models.AsyncBookingRejected,
+		Reason:    "booking request could not be processed, please try again",
+		UpdatedAt: time.Now(),
+	}
+	if err := h.redisClient.SetBookingRequestStatus(ctx, st); err != nil {
+		log.Printf("failed to store booking request %s status: %v", req.RequestID, err)
+	}
+}
+
+func (h *Handler) allocateEventBookings(ctx context.Context, eventId int64, reqs []models.AsyncBookingRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// bookSeatsTx locks the same row again, that is free inside the tx
+	var eventName string
+	var eventDate time.Time
+	err = tx.QueryRowContext(ctx, "SELECT name, date FROM event WHERE id = ? FOR UPDATE", eventId).Scan(&eventName, &eventDate)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	eventFound := err == nil
+
+	outcomes := make([]models.AsyncBookingStatus, 0, len(reqs))
+	for _, r := range reqs {
+		st := models.AsyncBookingStatus{
+			RequestID: r.RequestID,
+			EventID:   r.EventID,
+			UserID:    r.UserID,
+			UpdatedAt: time.Now(),
+		}
+
+		if !eventFound {
+			st.Status = models.AsyncBookingRejected
+			st.Reason = errEventNotFound.Error()
+			outcomes = append(outcomes, st)
+			continue
+		}
+
+		// redelivered after a commit whose ack got lost, don't book twice
+		var bookingID int64
+		err := tx.QueryRowContext(ctx, "SELECT id, status, amount, currency, payment_expires_at FROM booking WHERE request_id = ?", r.RequestID).Scan(&bookingID, &st.BookingStatus, &st.Amount, &st.Currency, &st.PaymentExpiresAt)
+		if err == nil {
+			st.Status = models.AsyncBookingConfirmed
+			st.BookingID = &bookingID
+			outcomes = append(outcomes, st)
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		booked, err := h.bookSeatsTx(ctx, tx, seatRequest{
+			EventID: int(eventId),
+			UserID:  r.UserID,
+			Seats:   r.Seats,
+			TierID:  r.TierID,
+		})
+		if err != nil {
+			// sold out, limits, tier closed.. are outcomes, anything else
+			// fails the batch and it is redelivered
+			if code := bookingErrorStatus(err); code == http.StatusInternalServerError || code == http.StatusRequestTimeout {
+				return err
+			}
+			st.Status = models.AsyncBookingRejected
+			st.Reason = err.Error()
+			outcomes = append(outcomes, st)
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE booking SET request_id = ? WHERE id = ?", r.RequestID, booked.BookingID); err != nil {
+			return err
+		}
+
+		st.Status = models.AsyncBookingConfirmed
+		st.BookingID = &booked.BookingID
+		st.BookingStatus = booked.Status
+		st.Amount = booked.Amount
+		st.Currency = booked.Currency
+		st.PaymentExpiresAt = booked.PaymentExpiresAt
+		outcomes = append(outcomes, st)
+
+		// ticket is sent once the booking is paid for
+		if booked.Status == "CONFIRMED" {
+			pdfCont := newPdfContent(int(booked.BookingID), int(r.UserID), r.UserName, r.UserEmail, eventName, eventDate.Format(time.RFC3339), int(r.Seats))
+			pdfCont.TierName = booked.TierName
+			pdfCont.Amount = booked.Amount
+			pdfCont.Currency = booked.Currency
+			setTicketToken(pdfCont, eventId)
+
+			if err := addBookingMessage(ctx, tx, bus.BookingMsgID(pdfCont.BookingID), pdfCont); err != nil {
+				return err
+			}
+			if err := reminder.Schedule(ctx, tx, booked.BookingID, eventId); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, st := range outcomes {
+		if err := h.redisClient.SetBookingRequestStatus(ctx, st); err != nil {
+			log.Printf("failed to store booking request %s status: %v", st.RequestID, err)
+		}
+	}
+
+	return nil
+}
+
+// checkAdmission rejects booking attempts without a valid admission token
+// while the waiting room of the event is active, it writes the response
+// and returns false when the request must stop
+func (h *Handler) checkAdmission(ctx context.Context, c *gin.Context, eventId int, userId int64) bool {
+	if h.redisClient == nil {
+		return true
+	}
+
+	cfg, err := h.redisClient.GetWaitingRoom(ctx, int64(eventId))
+	if err != nil {
+		// can't tell if the queue is on, don't let the spike through to mysql
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "failed to check waiting room: " + err.Error(),
+		})
+		return false
+	}
+	if cfg == nil || !cfg.Active {
+		return true
+	}
+
+	ok, err := h.redisClient.ValidAdmission(ctx, int64(eventId), userId, c.GetHeader("X-Admission-Token"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "failed to check admission token: " + err.Error(),
+		})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            "a valid admission token is required, join the waiting room",
+			"waiting_room_url": fmt.Sprintf("/api/waiting-room/%d", eventId),
+		})
+		return false
+	}
+	return true
+}
+
+// setWaitingRoomHandler turns the waiting room of an event on or off,
+// organizers switch it on ahead of high demand on-sales
+func (h *Handler) setWaitingRoomHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+	org := o.(models.Organization)
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	var req models.WaitingRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Active && req.AdmitPerMinute <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "admit_per_minute must be > 0",
+		})
+		return
+	}
+	if req.TokenTTLSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "token_ttl_seconds must be >= 0",
+		})
+		return
+	}
+
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "waiting room is not available right now",
+		})
+		return
+	}
+
+	var id int64
+	if err := h.db.QueryRowContext(ctx, "SELECT id FROM event WHERE id = ? AND org_id = ?", eventId, org.Id).Scan(&id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event not found",
+		})
+		return
+	}
+
+	cfg := models.WaitingRoomConfig{
+		EventID:         id,
+		Active:          req.Active,
+		AdmitPerMinute:  req.AdmitPerMinute,
+		TokenTTLSeconds: req.TokenTTLSeconds,
+		StartsAt:        time.Now(),
+	}
+	if cfg.TokenTTLSeconds == 0 {
+		cfg.TokenTTLSeconds = int64(admissionTokenTTL / time.Second)
+	}
+	if req.StartsAt != nil {
+		cfg.StartsAt = *req.StartsAt
+	}
+
+	if err := h.redisClient.SetWaitingRoom(ctx, cfg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to save waiting room: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "waiting room updated",
+		"data":    cfg,
+	})
+}
+
+// joinWaitingRoomHandler puts the user in the queue of an event
+func (h *Handler) joinWaitingRoomHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	cfg, ok := h.activeWaitingRoom(ctx, c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.redisClient.JoinWaitingRoom(ctx, cfg.EventID, u.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to join waiting room: " + err.Error(),
+		})
+		return
+	}
+
+	status, err := h.redisClient.WaitingRoomStatus(ctx, *cfg, u.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "joined the waiting room",
+		"data":    status,
+	})
+}
+
+// getWaitingRoomStatusHandler is polled by the client for its position,
+// the admission token shows up once the user's batch is let in
+func (h *Handler) getWaitingRoomStatusHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	cfg, ok := h.activeWaitingRoom(ctx, c)
+	if !ok {
+		return
+	}
+
+	status, err := h.redisClient.WaitingRoomStatus(ctx, *cfg, u.Id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotInQueue) || errors.Is(err, storage.ErrAdmissionExpired) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "waiting room status",
+		"data":    status,
+	})
+}
+
+// activeWaitingRoom loads the waiting room of the :event_id param, it
+// writes the response and returns false when there is none active
+func (h *Handler) activeWaitingRoom(ctx context.Context, c *gin.Context) (*models.WaitingRoomConfig, bool) {
+	eventId, err := strconv.Atoi(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return nil, false
+	}
+
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "waiting room is not available right now",
+		})
+		return nil, false
+	}
+
+	cfg, err := h.redisClient.GetWaitingRoom(ctx, int64(eventId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if cfg == nil || !cfg.Active {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event has no active waiting room, book directly",
+		})
+		return nil, false
+	}
+	return cfg, true
+}
+
+// confirmSeatHoldHandler turns an active hold into a booking row and
+// publishes the booking to NATS like seatBookingHandler does
+func (h *Handler) confirmSeatHoldHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	eventId, err := strconv.Atoi(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+	holdID := c.Param("hold_id")
+
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "seat holds are not available right now",
+		})
+		return
+	}
+
+	hold, err := h.redisClient.GetSeatHold(ctx, int64(eventId), holdID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hold == nil || hold.UserID != u.Id {
+		c.JSON(http.StatusNotFound, gin.H{"error": errHoldNotFound.Error()})
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "begainTx err:" + err.Error(),
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// hold is checked again under the event row lock
+	booked, err := h.bookSeatsTx(ctx, tx, seatRequest{
+		EventID: eventId,
+		UserID:  u.Id,
+		Seats:   hold.Seats,
+		TierID:  hold.TierID,
+		HoldID:  hold.HoldID,
+	})
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// hold may be a waitlist offer
+	if _, err := tx.ExecContext(ctx, "UPDATE waitlist SET status = 'CLAIMED' WHERE hold_id = ? AND user_id = ?", hold.HoldID, u.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var eventName string
+	var eventDate time.Time
+	if err := tx.QueryRowContext(ctx, "SELECT name, date FROM event WHERE id = ?", eventId).Scan(&eventName, &eventDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookingID := booked.BookingID
+	message := "seat booked successfully"
+	if booked.Status == "CONFIRMED" {
+		pdfCont := newPdfContent(int(bookingID), int(u.Id), u.FirstName, u.Email, eventName, eventDate.Format(time.RFC3339), int(hold.Seats))
+		pdfCont.TierName = booked.TierName
+		pdfCont.Amount = booked.Amount
+		pdfCont.Currency = booked.Currency
+		setTicketToken(pdfCont, hold.EventID)
+
+		if err := addBookingMessage(ctx, tx, bus.BookingMsgID(int(bookingID)), pdfCont); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue ticket: " + err.Error()})
+			return
+		}
+		if err := reminder.Schedule(ctx, tx, bookingID, hold.EventID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		message = "seats reserved, complete the payment to confirm the booking"
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
+		return
+	}
+
+	// seats are booked now, hold is no longer needed
+	if err := h.redisClient.ReleaseSeatHold(ctx, int64(eventId), hold.HoldID); err != nil {
+		log.Printf("failed to release seat hold %s: %v", hold.HoldID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data": gin.H{
+			"booking_id":         bookingID,
+			"event_id":           eventId,
+			"user_id":            u.Id,
+			"seats":              hold.Seats,
+			"tier_id":            hold.TierID,
+			"tier_name":          booked.TierName,
+			"amount":             booked.Amount,
+			"currency":           booked.Currency,
+			"status":             booked.Status,
+			"payment_expires_at": booked.PaymentExpiresAt,
+		},
+	})
+}
+
+// releaseSeatHoldHandler lets the user drop a hold before it expires
+func (h *Handler) releaseSeatHoldHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	eventId, err := strconv.Atoi(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+	holdID := c.Param("hold_id")
+
+	if h.redisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "seat holds are not available right now",
+		})
+		return
+	}
+
+	hold, err := h.redisClient.GetSeatHold(ctx, int64(eventId), holdID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hold == nil || hold.UserID != u.Id {
+		c.JSON(http.StatusNotFound, gin.H{"error": errHoldNotFound.Error()})
+		return
+	}
+
+	if err := h.redisClient.ReleaseSeatHold(ctx, int64(eventId), holdID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a released waitlist offer is declined, next in line gets the seats
+	if _, err := h.db.ExecContext(ctx, "UPDATE waitlist SET status = 'LEFT' WHERE hold_id = ? AND user_id = ? AND status = 'OFFERED'", holdID, u.Id); err != nil {
+		log.Printf("failed to update waitlist for hold %s: %v", holdID, err)
+	}
+	if err := h.processWaitlist(ctx, eventId); err != nil {
+		log.Printf("failed to process waitlist for event %d: %v", eventId, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("hold (%s) released", holdID),
+	})
+}
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// loadRefundPolicy returns the refund policy of an event, or the default
+// one when the organizer never set it
+func loadRefundPolicy(ctx context.Context, q rowQueryer, eventID int64) (models.RefundPolicy, error) {
+	var p models.RefundPolicy
+	query := "SELECT event_id, full_refund_days, partial_refund_percent, no_refund_hours, updated_at FROM refund_policy WHERE event_id = ?"
+	err := q.QueryRowContext(ctx, query, eventID).Scan(&p.EventId, &p.FullRefundDays, &p.PartialRefundPercent, &p.NoRefundHours, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return models.DefaultRefundPolicy(eventID), nil
+	}
+	return p, err
+}
+
+// getRefundPolicyHandler shows the refund rules of an event
+func (h *Handler) getRefundPolicyHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	policy, err := loadRefundPolicy(ctx, h.db, int64(eventId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "refund policy retrieved successfully",
+		"data":    policy,
+	})
+}
+
+// setRefundPolicyHandler lets the organizer set the refund rules of an
+// event: full refund until N days before, partial after, none within N hours
+func (h *Handler) setRefundPolicyHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+	org := o.(models.Organization)
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	var req models.RefundPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	if req.FullRefundDays < 0 || req.NoRefundHours < 0 || req.PartialRefundPercent < 0 || req.PartialRefundPercent > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "days & hours must be >= 0 and partial_refund_percent between 0 and 100",
+		})
+		return
+	}
+	if req.FullRefundDays*24 < req.NoRefundHours {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "full refund window must end before the no refund window starts",
+		})
+		return
+	}
+
+	var id int64
+	if err := h.db.QueryRowContext(ctx, "SELECT id FROM event WHERE id = ? AND org_id = ?", eventId, org.Id).Scan(&id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event not found",
+		})
+		return
+	}
+
+	query := `INSERT INTO refund_policy (event_id, full_refund_days, partial_refund_percent, no_refund_hours) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE full_refund_days = VALUES(full_refund_days), partial_refund_percent = VALUES(partial_refund_percent), no_refund_hours = VALUES(no_refund_hours)`
+	if _, err := h.db.ExecContext(ctx, query, eventId, req.FullRefundDays, req.PartialRefundPercent, req.NoRefundHours); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "refund policy updated successfully",
+		"data": models.RefundPolicy{
+			EventId:              id,
+			FullRefundDays:       req.FullRefundDays,
+			PartialRefundPercent: req.PartialRefundPercent,
+			NoRefundHours:        req.NoRefundHours,
+			UpdatedAt:            time.Now(),
+		},
+	})
+}
+
+// cancelTicketHandler cancels the whole booking or only some of its
+// seats (partial), the cancelled seats go back to the event and a
+// partially cancelled ticket is re-issued through the booking worker
+func (h *Handler) cancelTicketHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	// 1. fetch booking id
+	i := c.Param("booking_id")
+	bId, err := strconv.Atoi(i)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invaild booking id || wrong format",
+		})
+		return
+	}
+
+	// body is optional, no body (or seats = 0) cancels the whole booking
+	var req models.CancelBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "json binding error:" + err.Error(),
+		})
+		return
+	}
+	if req.Seats < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seats must be >= 0"})
+		return
+	}
+
+	// begain transaction
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "begainTx err:" + err.Error(),
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var eventID int
+	var status string
+	var seats, amount int64
+	var currency string
+	var tierID sql.NullInt64
+	var tierName sql.NullString
+	var intentID sql.NullString
+	var checkedInAt sql.NullTime
+	var eventName string
+	var eventDate time.Time
+	q := "SELECT b.event_id, b.status, b.seats, b.tier_id, b.amount, b.currency, b.payment_intent_id, b.checked_in_at, t.name, e.name, e.date FROM booking b JOIN event e ON b.event_id = e.id LEFT JOIN ticket_tier t ON t.id = b.tier_id WHERE b.id = ? AND b.user_id = ? FOR UPDATE"
+	if err := tx.QueryRowContext(ctx, q, bId, u.Id).Scan(&eventID, &status, &seats, &tierID, &amount, &currency, &intentID, &checkedInAt, &tierName, &eventName, &eventDate); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "booking not found",
+		})
+		return
+	}
+
+	if status == "CANCELLED" {
+		tx.Commit()
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "booking already cancelled",
+			"alreadyCancelled": true,
+		})
+		return
+	}
+
+	// seats of an unpaid booking were already given back
+	if status == "EXPIRED" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "booking expired before payment",
+		})
+		return
+	}
+
+	if checkedInAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "ticket already used at the entry",
+		})
+		return
+	}
+
+	seatsToCancel := req.Seats
+	if seatsToCancel == 0 {
+		seatsToCancel = seats
+	}
+	if seatsToCancel > seats {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("booking only has %d seats", seats),
+		})
+		return
+	}
+	remaining := seats - seatsToCancel
+	// value of the cancelled seats, amount always follows the seats held
+	cancelledAmount := amount * seatsToCancel / seats
+
+	// the intent was made for the old amount, void it before it is dropped
+	// so it can't be paid anymore
+	if remaining > 0 && status == "PENDING_PAYMENT" && intentID.Valid {
+		intent, err := h.payments.Cancel(ctx, intentID.String)
+		if err != nil && !errors.Is(err, payment.ErrIntentNotFound) {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": "failed to cancel payment intent: " + err.Error(),
+			})
+			return
+		}
+		if err == nil && intent.Status == payment.StatusSucceeded {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "payment of this booking already went through, cancel seats once it is confirmed",
+			})
+			return
+		}
+	}
+
+	// update booking first, a full cancellation keeps the original seats
+	if remaining == 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE booking SET status = 'CANCELLED' WHERE id = ?", bId)
+	} else if status == "PENDING_PAYMENT" {
+		// not paid yet, amount shrinks & a new payment intent is needed
+		_, err = tx.ExecContext(ctx, "UPDATE booking SET seats = ?, amount = ?, payment_intent_id = NULL WHERE id = ?", remaining, amount-cancelledAmount, bId)
+	} else {
+		_, err = tx.ExecContext(ctx, "UPDATE booking SET seats = ?, amount = ? WHERE id = ?", remaining, amount-cancelledAmount, bId)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// nothing left to remind of
+	if remaining == 0 {
+		if err := reminder.Cancel(ctx, tx, int64(bId)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// cancellation history
+	res, err := tx.ExecContext(ctx, "INSERT INTO booking_cancellation (booking_id, seats, remaining_seats) VALUES (?, ?, ?)", bId, seatsToCancel, remaining)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cancellationID, err := res.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// give back exactly the seats cancelled
+	_, err = tx.ExecContext(ctx, "UPDATE event SET seats_available = seats_available + ? WHERE id = ?", seatsToCancel, eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tierID.Valid {
+		_, err = tx.ExecContext(ctx, "UPDATE ticket_tier SET seats_available = seats_available + ? WHERE id = ?", seatsToCancel, tierID.Int64)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// money back only for what was actually paid, as per the event policy
+	var refund *models.Refund
+	if status == "CONFIRMED" && intentID.Valid && cancelledAmount > 0 {
+		policy, err := loadRefundPolicy(ctx, tx, int64(eventID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		refund, err = createRefundTx(ctx, tx, int64(bId), &cancellationID, intentID.String, cancelledAmount, currency, policy.RefundPercent(eventDate, time.Now()))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		err = addRefundMessage(ctx, tx, models.RefundPayload{
+			RefundID:  refund.Id,
+			BookingID: int64(bId),
+			UserName:  u.FirstName,
+			UserEmail: u.Email,
+			EventName: eventName,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue refund: " + err.Error()})
+			return
+		}
+	}
+
+	// re-issue the ticket pdf with the reduced seat count
+	if remaining > 0 && status == "CONFIRMED" {
+		pdfCont := newPdfContent(bId, int(u.Id), u.FirstName, u.Email, eventName, eventDate.Format(time.RFC3339), int(remaining))
+		pdfCont.SeatsCancelled = int(seatsToCancel)
+		pdfCont.TierName = tierName.String
+		pdfCont.Amount = amount - cancelledAmount
+		pdfCont.Currency = currency
+		setTicketToken(pdfCont, int64(eventID))
+
+		if err := addBookingMessage(ctx, tx, bus.BookingReissueMsgID(bId, cancellationID), pdfCont); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue ticket: " + err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
+		return
+	}
+
+	// freed seats go to the waitlist first
+	if err := h.processWaitlist(ctx, eventID); err != nil {
+		log.Printf("failed to process waitlist for event %d: %v", eventID, err)
+	}
+
+	message := fmt.Sprintf("ticket (%d) cancelled", bId)
+	if remaining > 0 {
+		message = fmt.Sprintf("%d seats of ticket (%d) cancelled", seatsToCancel, bId)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data": gin.H{
+			"booking_id":      bId,
+			"cancellation_id": cancellationID,
+			"seats_cancelled": seatsToCancel,
+			"seats_remaining": remaining,
+			"refund":          refund,
+		},
 	})
 }
 
-// for user, improved: redis cache miss/hit & context
-// timeouts err with pagination.
-// trying to convert this into upcomingEventsHandler,
-// Active events only (date >= now())
-func (h *Handler) listEventHandler(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+// getBookingRefunds returns the refunds of a booking with their status
+func (h *Handler) getBookingRefunds(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
-	// retrive cursor(id), limit from client request
-	idStr := c.DefaultQuery("id", fmt.Sprintf("%v", defaultCursor))
-	limitStr := c.DefaultQuery("limit", fmt.Sprintf("%v", defaultLimit))
-	cursor, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
+		})
 		return
 	}
+	u := user.(models.User)
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 10 {
-		limit = 10
+	bId, err := strconv.Atoi(c.Param("booking_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invaild booking id || wrong format",
+		})
+		return
 	}
 
-	var version int
-
-	// // cache check ->
-	if h.redisClient != nil {
-		// get verison
-		v, err := h.redisClient.GetEventVerison(ctx)
-		if err != nil || v == -1 {
-			fmt.Printf("version error on listing: %s\n", err)
-		}
-		version = v
+	query := `SELECT r.id, r.booking_id, r.cancellation_id, r.payment_intent_id, r.amount, r.currency, r.percent, r.status, r.gateway_refund_id, r.failure_reason, r.created_at, r.completed_at
+		FROM refund r JOIN booking b ON b.id = r.booking_id WHERE r.booking_id = ? AND b.user_id = ? ORDER BY r.id ASC`
+	rows, err := h.db.QueryContext(ctx, query, bId, u.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to query refunds",
+		})
+		return
+	}
+	defer rows.Close()
 
-		var cacheRes []models.EventResponse
-		cachedEvents, err := h.redisClient.GetCacheEvents(ctx, v, cursor, limit)
-		if err == nil && cachedEvents != nil {
-			for _, e := range cachedEvents {
-				// convert cache model to response model & generate image url
-				imageUrl := h.generateImageUrl(e.EventKey)
-				cacheRes = append(cacheRes, *newEventResponse(e.EventID, e.EventName, e.EventDate, imageUrl, e.OrganizationID, e.OrganizationName, e.City))
-			}
-			c.JSON(http.StatusOK, gin.H{
-				"message": "retrieved all events from cache",
-				"data":    cacheRes,
+	refunds := make([]models.Refund, 0)
+	for rows.Next() {
+		var r models.Refund
+		if err := rows.Scan(&r.Id, &r.BookingId, &r.CancellationId, &r.PaymentIntentID, &r.Amount, &r.Currency, &r.Percent, &r.Status, &r.GatewayRefundID, &r.FailureReason, &r.CreatedAt, &r.CompletedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to scan refund row",
 			})
 			return
 		}
+		refunds = append(refunds, r)
 	}
 
-	// cache miss -> list only event who are visible i.e public
-	query := "SELECT id, name, org_id, organized_by, image_key, capacity, seats_available, date, address, city, state, country, created_at FROM event WHERE visible = 'PUBLIC' AND date >= NOW() AND id > ? ORDER BY id ASC LIMIT ?"
-	rows, err := h.db.QueryContext(ctx, query, cursor, limit+1)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "refunds retrieved successfully",
+		"data":    refunds,
+		"count":   len(refunds),
+	})
+}
+
+// createRefundTx records the refund owed for a cancellation, percent comes
+// from the refund policy, nothing is recorded when the policy gives 0
+func createRefundTx(ctx context.Context, tx *sql.Tx, bookingID int64, cancellationID *int64, intentID string, amount int64, currency string, percent int) (*models.Refund, error) {
+	refundAmount := amount * int64(percent) / 100
+	if refundAmount <= 0 {
+		return nil, nil
+	}
+
+	query := "INSERT INTO refund (booking_id, cancellation_id, payment_intent_id, amount, currency, percent) VALUES (?, ?, ?, ?, ?, ?)"
+	res, err := tx.ExecContext(ctx, query, bookingID, cancellationID, intentID, refundAmount, currency, percent)
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			c.JSON(http.StatusRequestTimeout, gin.H{
-				"error": "query timeout",
-			})
-			return
-		}
+		return nil, err
+	}
 
-		if errors.Is(err, context.Canceled) {
-			c.JSON(499, gin.H{
-				"error": "request canceled by client",
-			})
-			return
-		}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Refund{
+		Id:              id,
+		BookingId:       bookingID,
+		CancellationId:  cancellationID,
+		PaymentIntentID: intentID,
+		Amount:          refundAmount,
+		Currency:        currency,
+		Percent:         percent,
+		Status:          "PENDING",
+		CreatedAt:       time.Now(),
+	}, nil
+}
+
+// addRefundMessage queues a refund recorded in tx for the refund worker,
+// the relay publishes it once tx is committed
+func addRefundMessage(ctx context.Context, tx *sql.Tx, payload models.RefundPayload) error {
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return outbox.Add(ctx, tx, bus.SubjectBookingRefund, bus.RefundMsgID(payload.RefundID), p)
+}
+
+// getBookingCancellations returns the cancellation history of a booking
+func (h *Handler) getBookingCancellations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
+		})
+		return
+	}
+	u := user.(models.User)
+
+	bId, err := strconv.Atoi(c.Param("booking_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invaild booking id || wrong format",
+		})
+		return
+	}
+
+	query := `SELECT bc.id, bc.booking_id, bc.seats, bc.remaining_seats, bc.cancelled_at FROM booking_cancellation bc JOIN booking b ON b.id = bc.booking_id WHERE bc.booking_id = ? AND b.user_id = ? ORDER BY bc.id ASC`
+	rows, err := h.db.QueryContext(ctx, query, bId, u.Id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+			"error": "failed to query cancellations",
 		})
 		return
 	}
 	defer rows.Close()
 
-	cacheEvents := make([]models.EventCache, 0, limit+1)
-	respEvents := make([]models.EventResponse, 0, limit+1)
+	cancellations := make([]models.BookingCancellation, 0)
 	for rows.Next() {
-		var event models.Event
-		if err := rows.Scan(
-			&event.Id,
-			&event.Name,
-			&event.OrgId,
-			&event.OrganizedBy,
-			&event.Key,
-			&event.Capacity,
-			&event.SeatsAvailable,
-			&event.Date,
-			&event.Address,
-			&event.City,
-			&event.State,
-			&event.Country,
-			&event.CreatedAt,
-		); err != nil {
+		var bc models.BookingCancellation
+		if err := rows.Scan(&bc.Id, &bc.BookingId, &bc.Seats, &bc.RemainingSeats, &bc.CancelledAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "failed to scan rows: " + err.Error(),
+				"error": "failed to scan cancellation row",
 			})
 			return
 		}
+		cancellations = append(cancellations, bc)
+	}
 
-		// response (with image URL)
-		respEvents = append(respEvents, *newEventResponse(
-			int(event.Id),
-			event.Name,
-			event.Date,
-			h.generateImageUrl(event.Key),
-			int(event.OrgId),
-			event.OrganizedBy,
-			event.City,
-		))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "cancellations retrieved successfully",
+		"data":    cancellations,
+		"count":   len(cancellations),
+	})
+}
 
-		// cache (without image URL)
-		cacheEvents = append(cacheEvents, *newEventCache(
-			int(event.Id),
-			event.Name,
-			event.Key,
-			event.Date,
-			int(event.OrgId),
-			event.OrganizedBy,
-			event.City,
-		))
+// checkInHandler validates a scanned ticket at the entry of an event of
+// the organization and marks it as used
+func (h *Handler) checkInHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
 	}
+	org := o.(models.Organization)
 
-	hasNext := false
-	if len(respEvents) > limit {
-		hasNext = true
-		respEvents = respEvents[:limit]
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
 	}
 
-	nextCursor := 0
-	if hasNext {
-		nextCursor = respEvents[len(respEvents)-1].EventID
+	var req models.CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid input: " + err.Error(),
+		})
+		return
+	}
+	if err := models.ValidGate(req.Gate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// cache set in redis
-	if err := h.redisClient.SetEventsCache(ctx, cacheEvents, version, cursor, limit); err != nil {
-		fmt.Printf("failed to set events cache: %v", err)
+	claims, err := ticket.Verify(req.Token)
+	if err != nil {
+		if errors.Is(err, ticket.ErrNoSecret) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if claims.EventID != int64(eventId) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "ticket is for a different event",
+		})
+		return
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to start transaction",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// lock the booking so two gates can't let the same ticket in
+	var status string
+	var checkedInAt sql.NullTime
+	var checkedInGate sql.NullString
+	var tierName sql.NullString
+	res := models.CheckInResponse{BookingID: claims.BookingID}
+	query := `SELECT b.status, b.seats, b.checked_in_at, b.checked_in_gate, t.name, CONCAT(u.first_name, ' ', u.last_name)
+		FROM booking b JOIN event e ON e.id = b.event_id JOIN user u ON u.id = b.user_id LEFT JOIN ticket_tier t ON t.id = b.tier_id
+		WHERE b.id = ? AND b.event_id = ? AND e.org_id = ? FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, claims.BookingID, eventId, org.Id).Scan(&status, &res.Seats, &checkedInAt, &checkedInGate, &tierName, &res.AttendeeName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	res.TierName = tierName.String
+
+	switch status {
+	case "CONFIRMED":
+	case "PENDING_PAYMENT":
+		c.JSON(http.StatusConflict, gin.H{"error": "ticket is not paid for"})
+		return
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "ticket is " + strings.ToLower(status)})
+		return
+	}
+
+	if checkedInAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "ticket already used",
+			"checked_in_at":   checkedInAt.Time,
+			"checked_in_gate": checkedInGate.String,
+		})
+		return
+	}
+
+	res.CheckedInAt = time.Now()
+	if _, err := tx.ExecContext(ctx, "UPDATE booking SET checked_in_at = ?, checked_in_gate = ? WHERE id = ?", res.CheckedInAt, req.Gate, claims.BookingID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to commit transaction",
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "events retrieved",
-		"data":        respEvents,
-		"has_next":    hasNext,
-		"next_cursor": nextCursor,
+		"message": "check-in successful",
+		"data":    res,
 	})
 }
 
-// TODO: pagination(limit & offset), wrong city -- not in use
-func (h *Handler) getEventByCityHandler(c *gin.Context) {
-	s := strings.TrimSpace(c.Param("city"))
-	state := strings.ToTitle(s)
+// checkInManifestHandler returns every ticket of an event as token hashes
+// so door staff can validate scans without connectivity
+func (h *Handler) checkInManifestHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+	org := o.(models.Organization)
+
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
+		return
+	}
+
+	var id int64
+	if err := h.db.QueryRowContext(ctx, "SELECT id FROM event WHERE id = ? AND org_id = ?", eventId, org.Id).Scan(&id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "event not found",
+		})
+		return
+	}
+
+	manifest := models.Manifest{
+		EventID:     id,
+		GeneratedAt: time.Now(),
+		Entries:     make([]models.ManifestEntry, 0),
+	}
 
-	q := "SELECT * FROM event WHERE city = ?"
-	rows, err := h.db.Query(q, state)
+	// cancelled tickets are listed too, so a scan of one is flagged at the door
+	query := "SELECT id, seats, status, checked_in_at FROM booking WHERE event_id = ? AND status IN ('CONFIRMED', 'CANCELLED') ORDER BY id ASC"
+	rows, err := h.db.QueryContext(ctx, query, eventId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+			"error": "failed to query bookings",
 		})
 		return
 	}
+	defer rows.Close()
 
-	var events []models.Event
 	for rows.Next() {
-		var e models.Event
-		if err := rows.Scan(&e.Id, &e.Name, &e.OrgId, &e.OrganizedBy, &e.Capacity, &e.SeatsAvailable, &e.Date, &e.Address, &e.City, &e.State, &e.Country, &e.CreatedAt); err != nil {
+		var e models.ManifestEntry
+		if err := rows.Scan(&e.BookingID, &e.Seats, &e.Status, &e.CheckedInAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "row scan error:" + err.Error(),
+				"error": "failed to scan booking row",
 			})
 			return
 		}
-		events = append(events, e)
+
+		token, err := ticket.Sign(e.BookingID, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		e.TokenHash = ticket.Hash(token)
+
+		manifest.Entries = append(manifest.Entries, e)
 	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "retrived events by city",
-		"data":    events,
+		"message": "manifest generated successfully",
+		"data":    manifest,
+		"count":   len(manifest.Entries),
 	})
 }
 
-func (h *Handler) getUpcomingEventCityHandler(c *gin.Context) {
-	city := c.Query("city")
-	exclude := c.Query("exclude")
-	excludeId, err := strconv.Atoi(exclude)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("wrong event id(%s) type, should be int", exclude),
+const maxSyncScans = 1000
+
+// checkInSyncHandler applies scans recorded offline, the earliest scan of
+// a ticket wins and everything else is reported back as a conflict
+func (h *Handler) checkInSyncHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	o, exists := c.Get("current_org")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
 		})
 		return
 	}
+	org := o.(models.Organization)
 
-	query := "SELECT * FROM event WHERE city = ? AND visible = 'PUBLIC' AND id != ? ORDER BY date ASC LIMIT 6"
-	rows, err := h.db.Query(query, city, excludeId)
+	eventId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
 		})
 		return
 	}
 
-	var eventRes []models.EventResponse
-	for rows.Next() {
-		var e models.Event
-		if err := rows.Scan(&e.Id, &e.Name, &e.OrgId, &e.OrganizedBy, &e.Capacity, &e.SeatsAvailable, &e.Date, &e.Address, &e.City, &e.State, &e.Country, &e.CreatedAt, &e.Key, &e.Visible); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "row scan error:" + err.Error(),
-			})
-			return
-		}
-		imageUrl := h.generateImageUrl(e.Key)
-		eventRes = append(eventRes, *newEventResponse(int(e.Id), e.Name, e.Date, imageUrl, int(e.OrgId), e.OrganizedBy, e.City))
-	}
-
-	if len(eventRes) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message": fmt.Sprintf("No upcoming events found for city: %v", city),
-			"data":    []interface{}{},
+	var req models.CheckInSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid input: " + err.Error(),
 		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("served upcoming events by city excluding (%v)", exclude),
-		"data":    eventRes,
-	})
-
-}
-
-func (h *Handler) getSeatsAvailabilityByEvent(c *gin.Context) {
-	i := c.Param("id")
-	id, err := strconv.Atoi(i)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+	if len(req.Scans) > maxSyncScans {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d scans per upload", maxSyncScans),
 		})
 		return
 	}
+	// scans without a gate are stored under the device id
+	if err := models.ValidGate(req.DeviceID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id: " + err.Error()})
+		return
+	}
+	for i, scan := range req.Scans {
+		if err := models.ValidGate(scan.Gate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scan %d: %v", i, err)})
+			return
+		}
+	}
 
-	query := "SELECT seats_available FROM event WHERE id = ?"
-	var seats int
-	if err := h.db.QueryRow(query, id).Scan(&seats); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+	result := models.CheckInSyncResult{Conflicts: make([]models.CheckInConflict, 0)}
+	conflict := func(scan models.CheckInScan, bookingID int64, reason string) {
+		result.Conflicts = append(result.Conflicts, models.CheckInConflict{
+			BookingID: bookingID,
+			Token:     scan.Token,
+			Gate:      scan.Gate,
+			ScannedAt: scan.ScannedAt,
+			Reason:    reason,
 		})
-		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "seats retrived!",
-		"data":    seats,
-	})
-}
+	// keep the earliest scan per booking, later ones are duplicates
+	now := time.Now()
+	earliest := make(map[int64]models.CheckInScan)
+	for _, scan := range req.Scans {
+		if scan.Gate == "" {
+			scan.Gate = req.DeviceID
+		}
+		// device clocks can't be trusted to be in the past
+		if scan.ScannedAt.IsZero() || scan.ScannedAt.After(now) {
+			scan.ScannedAt = now
+		}
 
-// for user & org -- in use
-func (h *Handler) getEventByIdHandler(c *gin.Context) {
-	strId := c.Param("id")
-	if strId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "event ID is required",
-		})
-		return
+		claims, err := ticket.Verify(scan.Token)
+		if err != nil {
+			if errors.Is(err, ticket.ErrNoSecret) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			conflict(scan, 0, models.ConflictInvalidToken)
+			continue
+		}
+		if claims.EventID != int64(eventId) {
+			conflict(scan, claims.BookingID, models.ConflictInvalidToken)
+			continue
+		}
+
+		prev, seen := earliest[claims.BookingID]
+		if !seen {
+			earliest[claims.BookingID] = scan
+			continue
+		}
+		if scan.ScannedAt.Before(prev.ScannedAt) {
+			earliest[claims.BookingID] = scan
+			scan = prev
+		}
+		conflict(scan, claims.BookingID, models.ConflictDuplicateScan)
 	}
 
-	id, err := strconv.Atoi(strId) //into int (from string)
+	// lock bookings in id order, concurrent uploads from other gates
+	// can't deadlock with us
+	bookingIds := make([]int64, 0, len(earliest))
+	for id := range earliest {
+		bookingIds = append(bookingIds, id)
+	}
+	sort.Slice(bookingIds, func(i, j int) bool { return bookingIds[i] < bookingIds[j] })
+
+	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+			"error": "failed to start transaction",
 		})
 		return
 	}
+	defer tx.Rollback()
 
-	query := "SELECT id, name, org_id, organized_by, image_key, capacity, seats_available, date, address, city, state, country, created_at, visible FROM event WHERE id = ?"
-	row := h.db.QueryRow(query, id)
-	var event models.Event
-	if err := row.Scan(&event.Id, &event.Name, &event.OrgId, &event.OrganizedBy, &event.Key, &event.Capacity, &event.SeatsAvailable, &event.Date, &event.Address, &event.City, &event.State, &event.Country, &event.CreatedAt, &event.Visible); err != nil {
+	query := "SELECT b.status, b.checked_in_at, b.checked_in_gate FROM booking b JOIN event e ON e.id = b.event_id WHERE b.id = ? AND b.event_id = ? AND e.org_id = ? FOR UPDATE"
+	for _, id := range bookingIds {
+		scan := earliest[id]
+
+		var status string
+		var checkedInAt sql.NullTime
+		var checkedInGate sql.NullString
+		err := tx.QueryRowContext(ctx, query, id, eventId, org.Id).Scan(&status, &checkedInAt, &checkedInGate)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				conflict(scan, id, models.ConflictNotFound)
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// e.g cancelled after the manifest was downloaded
+		if status != "CONFIRMED" {
+			conflict(scan, id, models.ConflictCancelled)
+			continue
+		}
+
+		if checkedInAt.Valid {
+			// same upload sent again
+			if checkedInGate.String == scan.Gate && checkedInAt.Time.Equal(scan.ScannedAt.Truncate(time.Second)) {
+				result.Accepted++
+				continue
+			}
+			conflict(scan, id, models.ConflictAlreadyCheckedIn)
+			last := &result.Conflicts[len(result.Conflicts)-1]
+			last.CheckedInAt = &checkedInAt.Time
+			last.CheckedInGate = checkedInGate.String
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE booking SET checked_in_at = ?, checked_in_gate = ? WHERE id = ?", scan.ScannedAt.Truncate(time.Second), scan.Gate, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		result.Accepted++
+	}
+
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "row scan error:" + err.Error(),
+			"error": "failed to commit transaction",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("found event by id:%v", event.Id),
-		"data":    event,
+		"message": fmt.Sprintf("%d scans accepted, %d conflicts", result.Accepted, len(result.Conflicts)),
+		"data":    result,
 	})
-
 }
 
-// used for event image upload
-func (h *Handler) getPresignedUrl(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+// createPaymentIntentHandler starts the payment of a PENDING_PAYMENT
+// booking with the configured gateway
+func (h *Handler) createPaymentIntentHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	// get org info thrugh org-middleware
-	currOrg, exists := c.Get("current_org")
+	user, exists := c.Get("current_user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "user not found in context",
 		})
 		return
 	}
-	org := currOrg.(models.Organization)
+	u := user.(models.User)
 
-	var req struct {
-		FileName string `json:"fileName"`
-		FileType string `json:"fileType"`
+	bId, err := strconv.Atoi(c.Param("booking_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invaild booking id || wrong format",
+		})
+		return
 	}
 
-	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	var b models.Booking
+	query := "SELECT id, amount, currency, status, payment_intent_id, payment_expires_at FROM booking WHERE id = ? AND user_id = ?"
+	if err := h.db.QueryRowContext(ctx, query, bId, u.Id).Scan(&b.Id, &b.Amount, &b.Currency, &b.Status, &b.PaymentIntentID, &b.PaymentExpiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// key -> /events/uploads/1/demo.png
-	key := fmt.Sprintf("events/uploads/%d/%s", org.Id, req.FileName)
+	if b.Status != "PENDING_PAYMENT" || (b.PaymentExpiresAt != nil && time.Now().After(*b.PaymentExpiresAt)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPayable.Error()})
+		return
+	}
 
-	url, err := h.s3.GetPresignUploadURL(ctx, awsBucketName, key)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("failed to generate presigned url:%s", err.Error()),
+	// one open intent per booking
+	if b.PaymentIntentID != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "payment already started",
+			"data": gin.H{
+				"booking_id": b.Id,
+				"intent_id":  *b.PaymentIntentID,
+			},
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"uploadUrl":  url,
-		"presignKey": key,
+	intent, err := h.payments.CreateIntent(ctx, payment.IntentRequest{
+		BookingID: b.Id,
+		Amount:    b.Amount,
+		Currency:  b.Currency,
 	})
-}
-
-// get image url from cloudfront when given image key
-// for user -- in use (per event image url generation)
-func (h *Handler) getImageUrlPerEvent(c *gin.Context) {
-	key := c.Query("key")
-	if key == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "image key is required",
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "failed to create payment intent: " + err.Error(),
 		})
 		return
 	}
 
-	url := h.generateImageUrl(key)
-	c.JSON(http.StatusOK, gin.H{
-		"imageUrl": url,
+	res, err := h.db.ExecContext(ctx, "UPDATE booking SET payment_intent_id = ? WHERE id = ? AND status = 'PENDING_PAYMENT' AND payment_intent_id IS NULL", intent.ID, b.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "payment already started for this booking"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "payment intent created",
+		"data": gin.H{
+			"booking_id":         b.Id,
+			"intent":             intent,
+			"payment_expires_at": b.PaymentExpiresAt,
+		},
 	})
 }
 
-// getUserDetailHandler retrives the user email, name etc
-// gets user details from middleware
-func (h *Handler) getUserDetailHandler(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
+// confirmPaymentHandler captures the payment of a booking, the booking
+// gets CONFIRMED and the ticket is sent once the gateway succeeds
+func (h *Handler) confirmPaymentHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	user, exists := c.Get("current_user")
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "user doesn't exists or timeout",
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
 		})
 		return
 	}
-
 	u := user.(models.User)
 
-	var userDetail models.User
-	query := "SELECT first_name, last_name, email, created_at FROM user WHERE id = ?"
-	if err := h.db.QueryRowContext(ctx, query, u.Id).Scan(&userDetail.FirstName, &userDetail.LastName, &userDetail.Email, &userDetail.CreatedAt); err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			c.JSON(http.StatusRequestTimeout, gin.H{
-				"error": "query timeout",
-			})
+	bId, err := strconv.Atoi(c.Param("booking_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invaild booking id || wrong format",
+		})
+		return
+	}
+
+	var status string
+	var intentID sql.NullString
+	query := "SELECT status, payment_intent_id FROM booking WHERE id = ? AND user_id = ?"
+	if err := h.db.QueryRowContext(ctx, query, bId, u.Id).Scan(&status, &intentID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-		if errors.Is(err, context.Canceled) {
-			c.JSON(499, gin.H{
-				"error": "request canceled by client",
-			})
-			return
+	if status == "CONFIRMED" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "booking already paid",
+			"data":    bId,
+		})
+		return
+	}
+	if status != "PENDING_PAYMENT" {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPayable.Error()})
+		return
+	}
+	if !intentID.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "create a payment intent first"})
+		return
+	}
+
+	intent, err := h.payments.Confirm(ctx, intentID.String)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "payment confirmation failed: " + err.Error(),
+		})
+		return
+	}
+
+	if intent.Status == payment.StatusFailed {
+		// let the user retry with a new intent before the deadline
+		if _, err := h.db.ExecContext(ctx, "UPDATE booking SET payment_intent_id = NULL WHERE id = ? AND payment_intent_id = ?", bId, intent.ID); err != nil {
+			log.Printf("failed to clear payment intent of booking %d: %v", bId, err)
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch user detail" + err.Error(),
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error": "payment failed: " + intent.FailureReason,
 		})
 		return
 	}
-	userDetail.Id = u.Id
+
+	if intent.Status != payment.StatusSucceeded {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "payment is being processed",
+			"data":    intent,
+		})
+		return
+	}
+
+	if err := h.markBookingPaid(ctx, int64(bId), intent.ID); err != nil {
+		if errors.Is(err, errNotPayable) {
+			// booking expired while paying, give the money back, the
+			// webhook retries if this fails
+			if err := h.refundUnpayableBooking(ctx, intent); err != nil {
+				log.Printf("failed to refund intent %s: %v", intent.ID, err)
+			}
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("user detail retrived for user with id %d", u.Id),
-		"data":    userDetail,
+		"message": "payment successful, booking confirmed",
+		"data": gin.H{
+			"booking_id": bId,
+			"intent":     intent,
+		},
 	})
 }
 
-// getUserBooking is used to fetch all bookings for the
-// user with event details, user detail is provided by middleware
-func (h *Handler) getUserBookings(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+// paymentWebhookHandler receives gateway callbacks, the signature is
+// checked by the gateway itself
+func (h *Handler) paymentWebhookHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	user, exists := c.Get("current_user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user not authenticated",
-		})
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
 		return
 	}
-	u := user.(models.User)
-
-	query := `SELECT  b.id, b.event_id, b.seats, b.status, b.booked_at, e.name, e.date, e.city FROM booking b JOIN event e ON b.event_id = e.id WHERE b.user_id = ? ORDER BY b.booked_at DESC`
 
-	rows, err := h.db.QueryContext(ctx, query, u.Id)
+	event, err := h.payments.VerifyWebhook(body, c.GetHeader("X-Payment-Signature"))
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			c.JSON(http.StatusGatewayTimeout, gin.H{
-				"error": "request timeout or canceled",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to query bookings",
-		})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	var bookings []models.UserBookings
-	for rows.Next() {
-		var booking models.UserBookings
-		err := rows.Scan(&booking.Id, &booking.EventId, &booking.Seats, &booking.Status, &booking.BookedAt, &booking.EventName, &booking.Date, &booking.City)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "failed to scan booking row",
-			})
+	switch event.Type {
+	case payment.WebhookPaymentSucceeded:
+		if err := h.markBookingPaid(ctx, event.BookingID, event.IntentID); err != nil {
+			if !errors.Is(err, errNotPayable) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			// not acked, the gateway sends the webhook again
+			intent := &payment.Intent{ID: event.IntentID, BookingID: event.BookingID, Amount: event.Amount, Currency: event.Currency}
+			if err := h.refundUnpayableBooking(ctx, intent); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	case payment.WebhookPaymentFailed:
+		if _, err := h.db.ExecContext(ctx, "UPDATE booking SET payment_intent_id = NULL WHERE id = ? AND payment_intent_id = ?", event.BookingID, event.IntentID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+	default:
+		log.Printf("ignoring payment webhook of type %s", event.Type)
+	}
 
-		booking.UserId = u.Id
-		bookings = append(bookings, booking)
+	c.JSON(http.StatusOK, gin.H{"message": "webhook processed"})
+}
+
+// markBookingPaid confirms a PENDING_PAYMENT booking paid with intentID
+// and queues it on the outbox for the ticket pdf & mail
+func (h *Handler) markBookingPaid(ctx context.Context, bookingID int64, intentID string) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	if err = rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "error iterating bookings",
-		})
-		return
+	var status string
+	var eventID int64
+	var pdfCont models.PDFContent
+	var tierName sql.NullString
+	var paymentExpiresAt sql.NullTime
+	query := `SELECT b.status, b.payment_expires_at, b.event_id, b.user_id, b.seats, b.amount, b.currency, t.name, u.first_name, u.email, e.name, e.date
+		FROM booking b JOIN user u ON u.id = b.user_id JOIN event e ON e.id = b.event_id LEFT JOIN ticket_tier t ON t.id = b.tier_id
+		WHERE b.id = ? AND b.payment_intent_id = ? FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, bookingID, intentID).Scan(&status, &paymentExpiresAt, &eventID, &pdfCont.UserID, &pdfCont.SeatsBooked, &pdfCont.Amount, &pdfCont.Currency, &tierName, &pdfCont.UserName, &pdfCont.UserEmail, &pdfCont.EventName, &pdfCont.EventDateTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errNotPayable
+		}
+		return err
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "bookings retrieved successfully",
-		"data":    bookings,
-		"count":   len(bookings),
-	})
+	// webhook & confirm call can both report the same payment
+	if status == "CONFIRMED" {
+		return nil
+	}
+	if status != "PENDING_PAYMENT" {
+		return errNotPayable
+	}
+	// past the deadline the seats are as good as released, the sweeper
+	// expires it
+	if paymentExpiresAt.Valid && time.Now().After(paymentExpiresAt.Time) {
+		return errNotPayable
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE booking SET status = 'CONFIRMED', paid_at = ? WHERE id = ?", time.Now(), bookingID); err != nil {
+		return err
+	}
+
+	pdfCont.BookingID = int(bookingID)
+	pdfCont.TierName = tierName.String
+	setTicketToken(&pdfCont, eventID)
+
+	if err := addBookingMessage(ctx, tx, bus.BookingMsgID(int(bookingID)), &pdfCont); err != nil {
+		return err
+	}
+	if err := reminder.Schedule(ctx, tx, bookingID, eventID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// getPDFPresignedURL handler returns booked ticket pdf
-// from s3 service to client, having param as bookingID
-func (h *Handler) getPDFPresignedURL(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+// refundUnpayableBooking records the refund of a payment captured for a
+// booking that expired or was cancelled in the meantime and queues it for
+// the refund worker. It is recorded once per intent, a replayed webhook or
+// the webhook after a confirm call finds it there and does nothing.
+func (h *Handler) refundUnpayableBooking(ctx context.Context, intent *payment.Intent) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	user, exists := c.Get("current_user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user not authenticated",
-		})
-		return
+	var currency string
+	p := models.RefundPayload{BookingID: intent.BookingID}
+	query := `SELECT b.currency, u.first_name, u.email, e.name
+		FROM booking b JOIN user u ON u.id = b.user_id JOIN event e ON e.id = b.event_id WHERE b.id = ?`
+	if err := tx.QueryRowContext(ctx, query, intent.BookingID).Scan(&currency, &p.UserName, &p.UserEmail, &p.EventName); err != nil {
+		return fmt.Errorf("read booking %d for refund: %w", intent.BookingID, err)
 	}
-	u := user.(models.User)
 
-	bId := c.Param("booking_id")
-	bookingID, err := strconv.Atoi(bId)
+	// what the intent captured, the booking amount may have changed since
+	// (partial cancel before paying)
+	if intent.Amount <= 0 {
+		return nil
+	}
+	if intent.Currency != "" {
+		currency = intent.Currency
+	}
+
+	query = "INSERT INTO refund (booking_id, payment_intent_id, amount, currency, percent, reason) VALUES (?, ?, ?, ?, 100, 'UNPAYABLE')"
+	res, err := tx.ExecContext(ctx, query, intent.BookingID, intent.ID, intent.Amount, currency)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "booking ID is required || inviald booking ID format!",
-		})
-		return
+		if isDuplicateKeyErr(err) {
+			return nil
+		}
+		return err
+	}
+	if p.RefundID, err = res.LastInsertId(); err != nil {
+		return err
 	}
 
-	bucketName := "ticket-one"
-	keyName := fmt.Sprintf("receipt/user-%d/booking_%d.pdf", u.Id, bookingID)
+	if err := addRefundMessage(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	url, err := h.s3.GetPresignDownloadURL(ctx, bucketName, keyName, 10)
+// expireBooking releases the seats of a booking not paid in time
+func (h *Handler) expireBooking(ctx context.Context, bookingID int64) error {
+	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			c.JSON(http.StatusGatewayTimeout, gin.H{
-				"error": "request timeout or canceled",
-			})
-			return
+		return err
+	}
+	defer tx.Rollback()
+
+	var eventID int
+	var seats int64
+	var tierID sql.NullInt64
+	query := "SELECT event_id, seats, tier_id FROM booking WHERE id = ? AND status = 'PENDING_PAYMENT' AND payment_expires_at < ? FOR UPDATE"
+	if err := tx.QueryRowContext(ctx, query, bookingID, time.Now()).Scan(&eventID, &seats, &tierID); err != nil {
+		// paid or cancelled in the meantime
+		if err == sql.ErrNoRows {
+			return nil
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to query bookings",
-		})
-		return
+		return err
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("presigned url genrated for bookingId:%d", bookingID),
-		"data":    url,
-	})
+	if _, err := tx.ExecContext(ctx, "UPDATE booking SET status = 'EXPIRED' WHERE id = ?", bookingID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE event SET seats_available = seats_available + ? WHERE id = ?", seats, eventID); err != nil {
+		return err
+	}
+	if tierID.Valid {
+		if _, err := tx.ExecContext(ctx, "UPDATE ticket_tier SET seats_available = seats_available + ? WHERE id = ?", seats, tierID.Int64); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// freed seats go to the waitlist first
+	return h.processWaitlist(ctx, eventID)
 }
 
-// seatBookingHandler is to booking seats for client &
-// provides payload to NATS server for pdf genration
-// and email notification
-func (h *Handler) seatBookingHandler(c *gin.Context) {
-	// create context with timeout
+// runPaymentExpirySweeper expires unpaid bookings past their deadline
+func (h *Handler) runPaymentExpirySweeper(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rows, err := h.db.QueryContext(ctx, "SELECT id FROM booking WHERE status = 'PENDING_PAYMENT' AND payment_expires_at < ? LIMIT 100", time.Now())
+			if err != nil {
+				log.Printf("payment expiry sweep error: %v", err)
+				continue
+			}
+
+			var bookingIds []int64
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					log.Printf("row scan error: %v", err)
+					continue
+				}
+				bookingIds = append(bookingIds, id)
+			}
+			rows.Close()
+
+			for _, id := range bookingIds {
+				if err := h.expireBooking(ctx, id); err != nil {
+					log.Printf("failed to expire booking %d: %v", id, err)
+				}
+			}
+		}
+	}
+}
+
+// joinWaitlistHandler puts the user in the FIFO waitlist of a sold out
+// event, seats freed later are offered to the waitlist in order
+func (h *Handler) joinWaitlistHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	// fetching user details through middleware
 	user, exists := c.Get("current_user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -1166,53 +5371,29 @@ func (h *Handler) seatBookingHandler(c *gin.Context) {
 	}
 	u := user.(models.User)
 
-	// event_id from the params
-	stringId := c.Param("event_id")
-	eventId, err := strconv.Atoi(stringId)
+	eventId, err := strconv.Atoi(c.Param("event_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
+			"error": "invalid event id",
 		})
-		return;
+		return
 	}
 
-	// booking details recived as body from user i.e number of seats
-	var b models.BookingRequest
-	if err := c.ShouldBindJSON(&b); err != nil {
+	var req models.WaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "json binding error:" + err.Error(),
 		})
 		return
 	}
 
-	// if user selected seats are less than zero (wrong value)
-	if b.Seats <= 0 {
+	if req.Seats <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "seats must be > 0"})
 		return
 	}
 
-	// begain transactional query
-	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelReadCommitted,
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "begainTx err:" + err.Error(),
-		})
-		return
-	}
-	// incase if any failure in between -> rollback
-	defer tx.Rollback()
-
-	// check if we do have enough seats_available
-	var seatsAvailable int
-	err = tx.QueryRowContext(
-		ctx,
-		"SELECT seats_available FROM event WHERE id = ? FOR UPDATE",
-		eventId,
-	).Scan(&seatsAvailable)
-
-	if err != nil {
+	var capacity int64
+	if err := h.db.QueryRowContext(ctx, "SELECT capacity FROM event WHERE id = ? AND visible = 'PUBLIC'", eventId).Scan(&capacity); err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
 			return
@@ -1221,74 +5402,124 @@ func (h *Handler) seatBookingHandler(c *gin.Context) {
 		return
 	}
 
+	if req.Seats > capacity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seats requested exceed event capacity"})
+		return
+	}
 
-	// if seats are available as per user demand
-	if seatsAvailable < int(b.Seats) {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "not enough seats available",
-		})
+	// waitlist is per tier on events with tiers
+	var hasTiers, tierFound bool
+	query := "SELECT EXISTS(SELECT 1 FROM ticket_tier WHERE event_id = ?), EXISTS(SELECT 1 FROM ticket_tier WHERE event_id = ? AND id = ?)"
+	if err := h.db.QueryRowContext(ctx, query, eventId, eventId, req.TierID).Scan(&hasTiers, &tierFound); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hasTiers && req.TierID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errTierRequired.Error()})
+		return
+	}
+	if req.TierID != 0 && !tierFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": errTierNotFound.Error()})
 		return
 	}
 
-	// update the event seats_available
-	query := "UPDATE event SET seats_available = seats_available - ? WHERE id = ?"
-	result, err := tx.ExecContext(ctx, query, b.Seats, eventId)
+	var onWaitlist bool
+	query = "SELECT EXISTS(SELECT 1 FROM waitlist WHERE event_id = ? AND user_id = ? AND status IN ('WAITING', 'OFFERED'))"
+	if err := h.db.QueryRowContext(ctx, query, eventId, u.Id).Scan(&onWaitlist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if onWaitlist {
+		c.JSON(http.StatusConflict, gin.H{"error": "already on the waitlist for this event"})
+		return
+	}
+
+	tierID := sql.NullInt64{Int64: req.TierID, Valid: req.TierID != 0}
+	res, err := h.db.ExecContext(ctx, "INSERT INTO waitlist (event_id, user_id, seats, tier_id) VALUES (?, ?, ?, ?)", eventId, u.Id, req.Seats, tierID)
 	if err != nil {
+		// joined concurrently, uniq_active_user keeps one entry
+		if isDuplicateKeyErr(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "already on the waitlist for this event"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
+			"error": "failed to join waitlist: " + err.Error(),
 		})
 		return
 	}
+	waitlistID, _ := res.LastInsertId()
 
-	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": errors.New("insufficient seats"),
-		})
-		return;
+	// seats may already be free, offer them right away
+	if err := h.processWaitlist(ctx, eventId); err != nil {
+		log.Printf("failed to process waitlist for event %d: %v", eventId, err)
 	}
 
-	//  insert record in booking table
-	query = "INSERT INTO booking (event_id, user_id, seats) VALUES (?, ?, ?)"
-	res, err := tx.ExecContext(ctx, query, eventId, u.Id, b.Seats)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "insufficient seats",
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "joined waitlist",
+		"data": gin.H{
+			"waitlist_id": waitlistID,
+			"event_id":    eventId,
+			"seats":       req.Seats,
+			"tier_id":     req.TierID,
+		},
+	})
+}
+
+// getWaitlistStatusHandler returns users waitlist position or offer
+func (h *Handler) getWaitlistStatusHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not found in context",
 		})
-		return;
+		return
 	}
+	u := user.(models.User)
 
-	bookingID, err := res.LastInsertId()
+	eventId, err := strconv.Atoi(c.Param("event_id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid event id",
+		})
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
+	var w models.WaitlistEntry
+	query := "SELECT id, event_id, user_id, seats, tier_id, status, hold_id, offer_expires_at, joined_at FROM waitlist WHERE event_id = ? AND user_id = ? AND status IN ('WAITING', 'OFFERED') ORDER BY id DESC LIMIT 1"
+	if err := h.db.QueryRowContext(ctx, query, eventId, u.Id).Scan(&w.Id, &w.EventId, &w.UserId, &w.Seats, &w.TierId, &w.Status, &w.HoldID, &w.OfferExpiresAt, &w.JoinedAt); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not on the waitlist for this event"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	pdfCont := newPdfContent(int(bookingID), int(u.Id), u.FirstName, u.Email, b.EventName, b.DateTime, int(b.Seats))
-
-	// pdf & notification payload to nats server (email/sms)
-	p, _ := json.Marshal(pdfCont)
-	if err = h.natsIns.PublishBookingEvent(ctx, int(bookingID), p); err != nil {
-		log.Printf("failed to publish booking event: %v", err)
+	// position among users still waiting, 0 once an offer is made
+	position := 0
+	if w.Status == "WAITING" {
+		query = "SELECT COUNT(*) FROM waitlist WHERE event_id = ? AND status = 'WAITING' AND id <= ?"
+		if err := h.db.QueryRowContext(ctx, query, eventId, w.Id).Scan(&position); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "seat booked successfully",
+		"message": "waitlist status retrieved",
 		"data": gin.H{
-			"booking_id": bookingID,
-			"event_id":   eventId,
-			"user_id":    u.Id,
-			"seats":      b.Seats,
+			"entry":    w,
+			"position": position,
 		},
 	})
-
 }
 
-func (h *Handler) cancelTicketHandler(c *gin.Context) {
+// leaveWaitlistHandler removes the user from the waitlist, an open offer
+// is released and passed on to the next user
+func (h *Handler) leaveWaitlistHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
@@ -1301,67 +5532,240 @@ func (h *Handler) cancelTicketHandler(c *gin.Context) {
 	}
 	u := user.(models.User)
 
-	// 1. fetch booking id
-	i := c.Param("booking_id")
-	bId, err := strconv.Atoi(i)
+	eventId, err := strconv.Atoi(c.Param("event_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invaild booking id || wrong format",
+			"error": "invalid event id",
 		})
 		return
 	}
 
-	// begain transaction
-	tx, err := h.db.BeginTx(ctx, nil)
+	var waitlistID int64
+	var status string
+	var holdID sql.NullString
+	query := "SELECT id, status, hold_id FROM waitlist WHERE event_id = ? AND user_id = ? AND status IN ('WAITING', 'OFFERED') ORDER BY id DESC LIMIT 1"
+	if err := h.db.QueryRowContext(ctx, query, eventId, u.Id).Scan(&waitlistID, &status, &holdID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not on the waitlist for this event"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.db.ExecContext(ctx, "UPDATE waitlist SET status = 'LEFT' WHERE id = ?", waitlistID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status == "OFFERED" && holdID.Valid && h.redisClient != nil {
+		if err := h.redisClient.ReleaseSeatHold(ctx, int64(eventId), holdID.String); err != nil {
+			log.Printf("failed to release waitlist hold %s: %v", holdID.String, err)
+		}
+		if err := h.processWaitlist(ctx, eventId); err != nil {
+			log.Printf("failed to process waitlist for event %d: %v", eventId, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "left waitlist",
+		"data":    waitlistID,
+	})
+}
+
+// processWaitlist offers free seats to waiting users in FIFO order. Each
+// offer is a seat hold of waitlistOfferTTL confirmed through the normal
+// hold confirm endpoint, the user is notified through NATS.
+func (h *Handler) processWaitlist(ctx context.Context, eventId int) error {
+	if h.redisClient == nil {
+		return nil
+	}
+
+	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "begainTx err:" + err.Error(),
-		})
-		return
+		return err
 	}
 	defer tx.Rollback()
 
-	var eventID int
-	var status string
-	q := "SELECT b.event_id, b.status FROM booking b JOIN event e ON b.event_id = e.id WHERE b.id = ? AND b.user_id = ? FOR UPDATE"
-	if err := tx.QueryRowContext(ctx, q, bId, u.Id).Scan(&eventID, &status); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "booking not found",
-		})
-		return
+	// same row lock as booking & holds, so offers never over commit seats
+	var seatsAvailable int64
+	var eventName string
+	var eventDate time.Time
+	err = tx.QueryRowContext(ctx, "SELECT seats_available, name, date FROM event WHERE id = ? FOR UPDATE", eventId).Scan(&seatsAvailable, &eventName, &eventDate)
+	if err != nil {
+		return err
 	}
 
-	if status == "CANCELLED" {
-		tx.Commit()
-		c.JSON(http.StatusOK, gin.H{
-			"message":          "booking already cancelled",
-			"alreadyCancelled": true,
-		})
-		return
+	// free seats per tier, tier 0 stands for events without tiers
+	tierFree := make(map[int64]int64)
+	tierRows, err := tx.QueryContext(ctx, "SELECT id, seats_available FROM ticket_tier WHERE event_id = ? FOR UPDATE", eventId)
+	if err != nil {
+		return err
+	}
+	for tierRows.Next() {
+		var id, seats int64
+		if err := tierRows.Scan(&id, &seats); err != nil {
+			tierRows.Close()
+			return err
+		}
+		tierFree[id] = seats
 	}
-	// update booking first
-	_, err = tx.ExecContext(ctx, "UPDATE booking SET status = 'CANCELLED' WHERE id = ?", bId)
+	tierRows.Close()
+
+	holds, err := h.redisClient.GetActiveHolds(ctx, int64(eventId))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
+	}
+	free := seatsAvailable
+	for _, hold := range holds {
+		free -= hold.Seats
+		if hold.TierID != 0 {
+			tierFree[hold.TierID] -= hold.Seats
+		}
+	}
+
+	// offers not claimed in time, their hold already expired in redis
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, "UPDATE waitlist SET status = 'EXPIRED' WHERE event_id = ? AND status = 'OFFERED' AND offer_expires_at < ?", eventId, now); err != nil {
+		return err
 	}
 
-	// update seats after i.e seats_available += 1
-	_, err = tx.ExecContext(ctx, "UPDATE event SET seats_available = seats_available + 1 WHERE id =? ", eventID)
+	query := "SELECT w.id, w.user_id, w.seats, COALESCE(w.tier_id, 0), u.first_name, u.email FROM waitlist w JOIN user u ON u.id = w.user_id WHERE w.event_id = ? AND w.status = 'WAITING' ORDER BY w.id ASC"
+	rows, err := tx.QueryContext(ctx, query, eventId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
+	}
+
+	type waitingUser struct {
+		userId int64
+		tierID int64
+		offer  models.WaitlistOfferPayload
+	}
+
+	var waiting []waitingUser
+	for rows.Next() {
+		var w waitingUser
+		if err := rows.Scan(&w.offer.WaitlistID, &w.userId, &w.offer.Seats, &w.tierID, &w.offer.UserName, &w.offer.UserEmail); err != nil {
+			rows.Close()
+			return err
+		}
+		waiting = append(waiting, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var offers []models.WaitlistOfferPayload
+	blocked := make(map[int64]bool)
+	for _, w := range waiting {
+		// strict FIFO per tier, a later smaller request never jumps the queue
+		if blocked[w.tierID] {
+			continue
+		}
+		if w.offer.Seats > free || (w.tierID != 0 && w.offer.Seats > tierFree[w.tierID]) {
+			blocked[w.tierID] = true
+			continue
+		}
+
+		hold := models.SeatHold{
+			HoldID:    randomID(),
+			EventID:   int64(eventId),
+			UserID:    w.userId,
+			TierID:    w.tierID,
+			Seats:     w.offer.Seats,
+			ExpiresAt: now.Add(waitlistOfferTTL),
+		}
+		if err := h.redisClient.CreateSeatHold(ctx, hold); err != nil {
+			h.releaseOfferHolds(offers)
+			return err
+		}
+
+		p := w.offer
+		p.HoldID = hold.HoldID
+		p.EventID = int64(eventId)
+		p.EventName = eventName
+		p.EventDate = eventDate
+		p.ExpiresAt = hold.ExpiresAt
+		offers = append(offers, p)
+
+		if _, err := tx.ExecContext(ctx, "UPDATE waitlist SET status = 'OFFERED', hold_id = ?, offer_expires_at = ? WHERE id = ?", hold.HoldID, hold.ExpiresAt, p.WaitlistID); err != nil {
+			h.releaseOfferHolds(offers)
+			return err
+		}
+		// the user hears of the offer iff it is saved
+		if err := addWaitlistOfferMessage(ctx, tx, p); err != nil {
+			h.releaseOfferHolds(offers)
+			return err
+		}
+		free -= p.Seats
+		if w.tierID != 0 {
+			tierFree[w.tierID] -= p.Seats
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "commit failed"})
-		return
+		h.releaseOfferHolds(offers)
+		return err
 	}
+	return nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("ticket (%d) cancelled", bId),
-		"data":    bId,
-	})
+// addWaitlistOfferMessage puts the offer mail in the outbox of tx
+func addWaitlistOfferMessage(ctx context.Context, tx *sql.Tx, offer models.WaitlistOfferPayload) error {
+	p, err := json.Marshal(offer)
+	if err != nil {
+		return err
+	}
+	return outbox.Add(ctx, tx, bus.SubjectWaitlistOffer, bus.WaitlistOfferMsgID(offer.WaitlistID, offer.HoldID), p)
+}
+
+// releaseOfferHolds drops holds created for offers that never got saved
+func (h *Handler) releaseOfferHolds(offers []models.WaitlistOfferPayload) {
+	for _, p := range offers {
+		if err := h.redisClient.ReleaseSeatHold(context.Background(), p.EventID, p.HoldID); err != nil {
+			log.Printf("failed to release waitlist hold %s: %v", p.HoldID, err)
+		}
+	}
+}
+
+// runWaitlistSweeper periodically re-processes waitlists, picking up seats
+// freed by expired holds & offers nobody claimed
+func (h *Handler) runWaitlistSweeper(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rows, err := h.db.QueryContext(ctx, "SELECT DISTINCT event_id FROM waitlist WHERE status IN ('WAITING', 'OFFERED')")
+			if err != nil {
+				log.Printf("waitlist sweep error: %v", err)
+				continue
+			}
+
+			var eventIds []int
+			for rows.Next() {
+				var id int
+				if err := rows.Scan(&id); err != nil {
+					log.Printf("row scan error: %v", err)
+					continue
+				}
+				eventIds = append(eventIds, id)
+			}
+			rows.Close()
+
+			for _, id := range eventIds {
+				if err := h.processWaitlist(ctx, id); err != nil {
+					log.Printf("failed to process waitlist for event %d: %v", id, err)
+				}
+			}
+		}
+	}
 }
 
 // listEventsByOrganization handler is to list all
@@ -1379,7 +5783,9 @@ func (h *Handler) listEventsByOrganization(c *gin.Context) {
 	}
 	org := o.(models.Organization)
 
-	query := "SELECT * FROM event WHERE org_id = ? AND visible = 'PUBLIC' OR visible = 'PRIVATE'"
+	query := `SELECT e.id, e.name, e.org_id, e.organized_by, e.capacity, e.seats_available, e.date, e.address, e.city, e.state, e.country, e.created_at, e.image_key, e.visible,
+		(SELECT COUNT(*) FROM waitlist w WHERE w.event_id = e.id AND w.status IN ('WAITING', 'OFFERED')) AS waitlist_count
+		FROM event e WHERE e.org_id = ? AND e.visible IN ('PUBLIC', 'PRIVATE', 'CANCELLED')`
 	rows, err := h.db.QueryContext(ctx, query, org.Id)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -1400,10 +5806,12 @@ func (h *Handler) listEventsByOrganization(c *gin.Context) {
 		})
 		return
 	}
+	defer rows.Close()
 
 	var respEvents []models.EventResponse
 	for rows.Next() {
 		var event models.Event
+		var waitlistCount int
 		if err := rows.Scan(
 			&event.Id,
 			&event.Name,
@@ -1419,6 +5827,7 @@ func (h *Handler) listEventsByOrganization(c *gin.Context) {
 			&event.CreatedAt,
 			&event.Key,
 			&event.Visible,
+			&waitlistCount,
 		); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "failed to scan rows: " + err.Error(),
@@ -1427,7 +5836,7 @@ func (h *Handler) listEventsByOrganization(c *gin.Context) {
 		}
 
 		imgaeUrl := h.generateImageUrl(event.Key)
-		respEvents = append(respEvents, *newEventResponse(
+		resp := newEventResponse(
 			int(event.Id),
 			event.Name,
 			event.Date,
@@ -1435,9 +5844,12 @@ func (h *Handler) listEventsByOrganization(c *gin.Context) {
 			int(event.OrgId),
 			event.OrganizedBy,
 			event.City,
-		))
+		)
+		resp.WaitlistCount = &waitlistCount
+		respEvents = append(respEvents, *resp)
 
 	}
+	h.withTags(ctx, respEvents)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "event retrived",
@@ -1479,6 +5891,26 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		return
 	}
 
+	// categories or tags left out are kept as they are
+	if err := updatedEvent.EventTags.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newPoint, err := pointOf(updatedEvent.Latitude, updatedEvent.Longitude)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// cancelling has to go through cancelEventHandler to reach bookings
+	if updatedEvent.Visible == "CANCELLED" || updatedEvent.Visible == "DELETED" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "use /api/event/:id/cancel to cancel an event",
+		})
+		return
+	}
+
 	// start trans
 	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -1494,19 +5926,35 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 	// lock row
 	var oldCapacity, oldAvailable int
 	var oldDate time.Time
-	var oldName, oldAddress, oldCity, oldState, oldCountry string
+	var oldName, oldAddress, oldCity, oldState, oldCountry, oldVisible string
+	var oldLat, oldLng sql.NullFloat64
+	var oldLimits models.BookingLimits
 
 	err = tx.QueryRowContext(
 		ctx,
-		`SELECT name, capacity, seats_available, date, address, city, state, country FROM event WHERE id = ? AND org_id = ? FOR UPDATE`, eventId, org.Id).Scan(&oldName, &oldCapacity, &oldAvailable, &oldDate, &oldAddress, &oldCity, &oldState, &oldCountry)
+		`SELECT name, capacity, seats_available, date, address, city, state, country, visible, latitude, longitude, max_seats_per_booking, max_seats_per_user, max_bookings_per_user FROM event WHERE id = ? AND org_id = ? FOR UPDATE`, eventId, org.Id).Scan(&oldName, &oldCapacity, &oldAvailable, &oldDate, &oldAddress, &oldCity, &oldState, &oldCountry, &oldVisible, &oldLat, &oldLng, &oldLimits.MaxSeatsPerBooking, &oldLimits.MaxSeatsPerUser, &oldLimits.MaxBookingsPerUser)
 
-	if err != nil {
+	if err != nil || oldVisible == "DELETED" {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "event not found",
 		})
 		return
 	}
 
+	if oldVisible == "CANCELLED" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "event has been cancelled",
+		})
+		return
+	}
+
+	// limits left out are kept, they only apply to new bookings
+	limits := oldLimits.Merge(updatedEvent.BookingLimits)
+	if err := limits.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// calculate booked seats
 	seatsBooked := oldCapacity - oldAvailable
 
@@ -1518,14 +5966,51 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		return
 	}
 
+	// tiers split the event capacity, it can't shrink below them
+	var tierCapacity int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(capacity), 0) FROM ticket_tier WHERE event_id = ?", eventId).Scan(&tierCapacity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to read ticket tiers: " + err.Error(),
+		})
+		return
+	}
+	if updatedEvent.Capacity < tierCapacity {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "capacity cannot be less than the total capacity of ticket tiers",
+		})
+		return
+	}
+
 	// recalculate available seats
 	newAvailable := updatedEvent.Capacity - seatsBooked
 
+	oldLocation := fmt.Sprintf("%s, %s, %s, %s", oldAddress, oldCity, oldState, oldCountry)
+	newLocation := fmt.Sprintf("%s, %s, %s, %s", updatedEvent.Address, updatedEvent.City, updatedEvent.State, updatedEvent.Country)
+
+	// coordinates sent win, a new address without them is geocoded once
+	// committed (not under the row lock), the old ones point elsewhere
+	lat, lng := oldLat, oldLng
+	regeocode := false
+	if newPoint != nil {
+		lat = sql.NullFloat64{Float64: newPoint.Lat, Valid: true}
+		lng = sql.NullFloat64{Float64: newPoint.Lng, Valid: true}
+	} else if oldLocation != newLocation {
+		lat, lng = sql.NullFloat64{}, sql.NullFloat64{}
+		regeocode = true
+	}
+
+	var description *string
+	if updatedEvent.Description != nil {
+		d := strings.TrimSpace(*updatedEvent.Description)
+		description = &d
+	}
+
 	// update event
 	_, err = tx.ExecContext(
 		ctx,
 		`UPDATE event
 		 SET name = ?,
+		     description = COALESCE(?, description),
 		     capacity = ?,
 		     seats_available = ?,
 		     date = ?,
@@ -1533,9 +6018,15 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		     city = ?,
 		     state = ?,
 		     country = ?,
-		     visible = ?
+		     latitude = ?,
+		     longitude = ?,
+		     visible = ?,
+		     max_seats_per_booking = ?,
+		     max_seats_per_user = ?,
+		     max_bookings_per_user = ?
 		 WHERE id = ? AND org_id = ?`,
 		updatedEvent.Name,
+		description,
 		updatedEvent.Capacity,
 		newAvailable,
 		updatedEvent.DateTime,
@@ -1543,7 +6034,12 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		updatedEvent.City,
 		updatedEvent.State,
 		updatedEvent.Country,
+		lat,
+		lng,
 		updatedEvent.Visible,
+		limits.MaxSeatsPerBooking,
+		limits.MaxSeatsPerUser,
+		limits.MaxBookingsPerUser,
 		eventId,
 		org.Id,
 	)
@@ -1555,10 +6051,9 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		return
 	}
 
-	// commit
-	if err := tx.Commit(); err != nil {
+	if err := saveEventTags(ctx, tx, int64(eventId), updatedEvent.EventTags); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to commit transaction",
+			"error": "failed to save event tags: " + err.Error(),
 		})
 		return
 	}
@@ -1572,6 +6067,14 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 			Old:  oldDate.Format(time.RFC3339),
 			New:  updatedEvent.DateTime.Format(time.RFC3339),
 		})
+
+		// reminders follow the new date
+		if err := reminder.Reschedule(ctx, tx, int64(eventId)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to reschedule reminders: " + err.Error(),
+			})
+			return
+		}
 	}
 
 	if oldName != updatedEvent.Name {
@@ -1582,10 +6085,6 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		})
 	}
 
-	oldLocation := fmt.Sprintf("%s, %s, %s, %s", oldAddress, oldCity, oldState, oldCountry)
-
-	newLocation := fmt.Sprintf("%s, %s, %s, %s", updatedEvent.Address, updatedEvent.City, updatedEvent.State, updatedEvent.Country)
-
 	if oldLocation != newLocation {
 		changes = append(changes, models.EventEditChange{
 			Type: models.EventLocationChanged,
@@ -1594,34 +6093,58 @@ func (h *Handler) updateEventHandler(c *gin.Context) {
 		})
 	}
 
-	// send update email if critical changes are changed
+	// send update email if critical changes are changed, queued in the
+	// outbox so the mail goes out iff the update is committed
 	if seatsBooked > 0 && len(changes) > 0 {
 		p := models.EventEditedPayload{
 			EventID:  int64(eventId),
 			Changes:  changes,
 			EditedAt: time.Now().UTC(),
 		}
-		rows, err := h.db.Query("SELECT DISTINCT u.email FROM booking b JOIN user u ON u.id = b.user_id WHERE b.event_id = ? AND b.status = 'CONFIRMED'", eventId)
+		rows, err := tx.QueryContext(ctx, "SELECT DISTINCT u.email FROM booking b JOIN user u ON u.id = b.user_id WHERE b.event_id = ? AND b.status = 'CONFIRMED'", eventId)
 		if err != nil {
-			fmt.Print(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		defer rows.Close()
-
 		for rows.Next() {
 			var userEmail string
 			if err := rows.Scan(&userEmail); err != nil {
 				log.Printf("row scan error: %v", err)
+				continue
 			}
 			p.To = append(p.To, userEmail)
 		}
+		rows.Close()
 
 		paylaod, err := json.Marshal(p)
 		if err != nil {
 			log.Printf("failed to marshal event edit payload: %v", err)
 		}
 
-		if err := h.natsIns.PublishEditEvent(ctx, eventId, paylaod); err != nil {
-			log.Printf("failed to publish edit-event event: %v", err)
+		if err := outbox.Add(ctx, tx, bus.SubjectEventEdit, bus.EditEventMsgID(eventId, p.EditedAt), paylaod); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to queue event update mail: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	// commit
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to commit transaction",
+		})
+		return
+	}
+
+	if regeocode {
+		h.geocodeEvent(ctx, int64(eventId), geo.Address{Street: updatedEvent.Address, City: updatedEvent.City, State: updatedEvent.State, Country: updatedEvent.Country})
+	}
+
+	// capacity raised, offer new seats to the waitlist
+	if newAvailable > oldAvailable {
+		if err := h.processWaitlist(ctx, eventId); err != nil {
+			log.Printf("failed to process waitlist for event %d: %v", eventId, err)
 		}
 	}
 
@@ -1655,7 +6178,7 @@ func main() {
 	cloudFrontURL = cfDomain
 
 	// Connection to Database.
-	db, err := connectDb()
+	db, err := storage.ConnectDB()
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
@@ -1666,24 +6189,96 @@ func main() {
 		fmt.Println(err)
 	}
 
-	// aws
-	cfg := cloud.LoadAwsConifg()
-	s3Service := cloud.NewS3Service(cfg)
+	// event images & ticket pdfs, s3 unless BLOB_STORE=local
+	blobs, err := blob.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// nats
+	// message bus, nats unless MESSAGE_BUS=memory
 	ctx := context.Background()
-	natsIns, err := nats.NewNATSIns()
+	var msgBus bus.Bus
+	var natsIns *nats.NATSIns
+	switch name := os.Getenv("MESSAGE_BUS"); name {
+	case "memory":
+		log.Println("using the in-memory message bus, workers run in this process")
+		msgBus = bus.NewMemory(bus.MemoryConfig{MaxAttempts: memoryBusMaxAttempts})
+	case "", "nats":
+		natsIns, err = nats.NewNATSIns()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := natsIns.CreateEventStream(ctx); err != nil {
+			fmt.Println(err)
+		}
+		natsIns.CreateBookingStream(ctx)
+		if err := natsIns.CreateAllocationStream(ctx); err != nil {
+			log.Fatal(err)
+		}
+		if err := natsIns.CreateDeadLetterStream(ctx); err != nil {
+			log.Fatal(err)
+		}
+		msgBus = natsIns
+	default:
+		log.Fatalf("unknown message bus: %q", name)
+	}
+
+	// payment gateway picked by PAYMENT_GATEWAY, fails without a webhook secret
+	gateway, err := payment.NewGatewayFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := natsIns.CreateEventStream(ctx); err != nil {
-		fmt.Println(err)
+
+	// smtp unless MAILER=file
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// QR codes of tickets are signed with TICKET_SECRET, check-in can't
+	// work without it
+	if err := ticket.CheckSecret(); err != nil {
+		log.Fatal(err)
+	}
+
+	// off unless GEOCODER picks one
+	geocoder, err := geo.NewGeocoderFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
-	natsIns.CreateBookingStream(ctx)
 
-	h := &Handler{db: db, redisClient: r, s3: s3Service, natsIns: natsIns}
+	h := &Handler{db: db, redisClient: r, blobs: blobs, mailer: mailer, msgBus: msgBus, payments: gateway, geocoder: geocoder}
 	// h := &Handler{db: db}
 
+	// `server allocator` runs the booking allocator instead of the api
+	if len(os.Args) > 1 && os.Args[1] == "allocator" {
+		h.runAllocator(natsIns)
+		return
+	}
+
+	// offers seats freed by expired holds to waitlisted users
+	go h.runWaitlistSweeper(ctx, time.Minute)
+	// releases seats of bookings not paid in time
+	go h.runPaymentExpirySweeper(ctx, 30*time.Second)
+	// weekly digest of upcoming events
+	go func() {
+		if err := digest.NewJob(db, 100).Run(ctx, 10*time.Minute); err != nil {
+			log.Printf("digest job stopped: %v", err)
+		}
+	}()
+	// finishes event cancellations cut short by a restart
+	go h.runEventCancellationSweeper(ctx, time.Minute)
+	// queues reminder mails of upcoming events
+	go func() {
+		if err := reminder.NewScheduler(db, 100).Run(ctx, time.Minute); err != nil {
+			log.Printf("reminder scheduler stopped: %v", err)
+		}
+	}()
+	// with nats the allocator & workers run as their own processes
+	if natsIns == nil {
+		h.runInProcessWorkers(ctx)
+	}
+
 	// read event.sql file and create table or can be done through workbench,
 	// but multiple sql commands in one file will fail.
 	// sqlfile, err := os.ReadFile("sql/event.sql")
@@ -1704,8 +6299,8 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key", "X-Admission-Token"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -1713,21 +6308,57 @@ func main() {
 	router.Use(gin.Logger())
 	router.GET("/", welcomeHandler)
 
+	// signed urls of the local blob store point here
+	if _, ok := blobs.(*blob.Local); ok {
+		router.GET(blob.LocalRoute+"*key", h.blobDownloadHandler)
+		router.PUT(blob.LocalRoute+"*key", h.blobUploadHandler)
+	}
+
 	router.POST("/api/auth/organization/register", h.createOrganization)    // working
 	router.POST("/api/auth/organization/login", h.loginOrganization)        // working
 	router.POST("/api/create-event", h.orgMiddleware, h.createEventHandler) // working
-	router.POST("/api/subscribe", h.orgMiddleware, h.subscribeHandler)      // TODO
+	router.GET("/api/organization/followers", h.orgMiddleware, h.getFollowerCountHandler)
+	router.POST("/api/organization/:id/follow", h.middleware, h.followOrganizationHandler)
+	router.DELETE("/api/organization/:id/follow", h.middleware, h.unfollowOrganizationHandler)
+	router.GET("/api/user/following", h.middleware, h.listFollowingHandler)
+	router.GET("/api/user/digest", h.middleware, h.getDigestSettingsHandler)
+	router.PUT("/api/user/digest", h.middleware, h.updateDigestSettingsHandler)
+	router.GET("/api/digest/unsubscribe", h.digestUnsubscribeHandler)
+	router.POST("/api/digest/unsubscribe", h.digestUnsubscribeHandler) // one click List-Unsubscribe-Post
 
 	router.POST("/api/auth/sign-in", h.createUser)                                         // working
 	router.POST("/api/auth/login", h.loginUser)                                            // working
 	router.GET("/api/events", h.listEventHandler)                                          // working & tested
 	router.GET("/about/organization/:id", h.aboutOrganization)                             // working & tested
 	router.GET("/api/event/:id", h.getEventByIdHandler)                                    // working & tested
+	router.GET("/api/event/:id/tiers", h.listTicketTiersHandler)
+	router.POST("/api/event/:id/tiers", h.orgMiddleware, h.createTicketTierHandler)
+	router.GET("/api/event/:id/refund-policy", h.getRefundPolicyHandler)
+	router.PUT("/api/event/:id/refund-policy", h.orgMiddleware, h.setRefundPolicyHandler)
+	router.POST("/api/event/:id/check-in", h.orgMiddleware, h.checkInHandler)
+	router.PUT("/api/event/:id/waiting-room", h.orgMiddleware, h.setWaitingRoomHandler)
+	router.POST("/api/waiting-room/:event_id", h.middleware, h.joinWaitingRoomHandler)
+	router.POST("/api/book-seats/:event_id/async", h.middleware, h.asyncBookingHandler)
+	router.GET("/api/booking-requests/:request_id", h.middleware, h.getAsyncBookingStatusHandler)
+	router.GET("/api/waiting-room/:event_id", h.middleware, h.getWaitingRoomStatusHandler)
+	router.GET("/api/event/:id/check-in/manifest", h.orgMiddleware, h.checkInManifestHandler)
+	router.POST("/api/event/:id/check-in/sync", h.orgMiddleware, h.checkInSyncHandler)
+	router.GET("/api/events/search", h.searchEventsHandler)
+	router.GET("/api/events/near", h.nearbyEventsHandler)
+	router.GET("/api/events/categories", categoriesHandler)
 	router.GET("/api/events/upcoming", h.getUpcomingEventCityHandler)                      // working & tested
 	router.POST("/api/event/image/upload-url", h.orgMiddleware, h.getPresignedUrl)         // working & tested
 	router.GET("/api/event/image", h.getImageUrlPerEvent)                                  // working & tested
 	router.POST("/api/book-seats/:event_id", h.middleware, h.seatBookingHandler)           // working & tested
-	router.PUT("/api/delete/event/:id", h.DeletEvenHandler)                                // working & tested
+	router.POST("/api/book-seats/:event_id/hold", h.middleware, h.createSeatHoldHandler)
+	router.POST("/api/book-seats/:event_id/hold/:hold_id/confirm", h.middleware, h.confirmSeatHoldHandler)
+	router.DELETE("/api/book-seats/:event_id/hold/:hold_id", h.middleware, h.releaseSeatHoldHandler)
+	router.POST("/api/waitlist/:event_id", h.middleware, h.joinWaitlistHandler)
+	router.GET("/api/waitlist/:event_id", h.middleware, h.getWaitlistStatusHandler)
+	router.DELETE("/api/waitlist/:event_id", h.middleware, h.leaveWaitlistHandler)
+	router.PUT("/api/delete/event/:id", h.orgMiddleware, h.cancelEventHandler) // kept for old clients
+	router.POST("/api/event/:id/cancel", h.orgMiddleware, h.cancelEventHandler)
+	router.GET("/api/event/:id/cancellation", h.orgMiddleware, h.getEventCancellationHandler)
 	router.GET("/api/organization/my-events", h.orgMiddleware, h.listEventsByOrganization) // working & tested
 	router.PUT("/api/update/event/:id", h.orgMiddleware, h.updateEventHandler)             // working & tested
 	router.GET("/api/profile/user", h.middleware, h.getUserDetailHandler)                  // working & tested
@@ -1735,6 +6366,11 @@ func main() {
 
 	router.GET("/api/pdf/booking/:booking_id", h.middleware, h.getPDFPresignedURL) // tested...
 	router.PUT("/api/booking/:booking_id", h.middleware, h.cancelTicketHandler)    // testing/...
+	router.GET("/api/booking/:booking_id/cancellations", h.middleware, h.getBookingCancellations)
+	router.GET("/api/booking/:booking_id/refunds", h.middleware, h.getBookingRefunds)
+	router.POST("/api/payment/:booking_id/intent", h.middleware, h.createPaymentIntentHandler)
+	router.POST("/api/payment/:booking_id/confirm", h.middleware, h.confirmPaymentHandler)
+	router.POST("/api/payment/webhook", h.paymentWebhookHandler)
 
 	router.GET("/api/event/seats/:id", h.getSeatsAvailabilityByEvent) //working (not in use rn)
 	router.GET("/api/events/:city", h.getEventByCityHandler)
@@ -1747,13 +6383,162 @@ func (h *Handler) generateImageUrl(key string) string {
 	// using cloudfront domain directly for better performance
 	// url := fmt.Sprintf("%s/%s", cloudFrontURL, key)
 
-	url, err := h.s3.GetPresignDownloadURL(context.TODO(), awsBucketName, key, 10)
+	// events without an image
+	if key == "" {
+		return ""
+	}
+
+	url, err := h.blobs.DownloadURL(context.TODO(), key, 10*time.Minute)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to generate image url of %q: %v", key, err)
+		return ""
 	}
 	return url
 }
 
+// blobDownloadHandler serves a file of the local blob store to the
+// holder of a signed download url
+func (h *Handler) blobDownloadHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	local, ok := h.blobs.(*blob.Local)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.Verify(blob.OpGet, key, c.Query("exp"), c.Query("sig")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, contentType, err := local.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// blobUploadHandler stores the body sent to a signed upload url of the
+// local blob store, like a PUT to an s3 presigned url
+func (h *Handler) blobUploadHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	local, ok := h.blobs.(*blob.Local)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.Verify(blob.OpPut, key, c.Query("exp"), c.Query("sig")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBlobUploadBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if int64(len(data)) > maxBlobUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		return
+	}
+
+	if err := local.Put(ctx, key, data, c.ContentType()); err != nil {
+		if errors.Is(err, blob.ErrInvalidKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// random hex id used for holds & request ids
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("crypto/rand failed: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// runInProcessWorkers starts the outbox relay, the allocator and the
+// workers on h.msgBus, used when there is no nats server to run them
+// as separate processes
+func (h *Handler) runInProcessWorkers(ctx context.Context) {
+	relay := outbox.NewRelay(h.db, h.msgBus, 100)
+	go func() {
+		if err := relay.Run(ctx, time.Second); err != nil {
+			log.Printf("outbox relay stopped: %v", err)
+		}
+	}()
+
+	subscribe := func(subject, durable string, handler bus.Handler) {
+		go func() {
+			if err := h.msgBus.Subscribe(ctx, subject, durable, handler); err != nil {
+				log.Printf("%s stopped: %v", durable, err)
+			}
+		}()
+	}
+
+	// one request at a time, there is no batch fetch on a generic bus
+	subscribe(bus.SubjectBookingRequest, "booking-allocator", func(ctx context.Context, msg bus.Message) error {
+		var req models.AsyncBookingRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return fmt.Errorf("invalid booking request payload: %w", err)
+		}
+		err := h.allocateBookings(ctx, []models.AsyncBookingRequest{req})
+		if err != nil && msg.Attempt >= uint64(memoryBusMaxAttempts) {
+			// dead-lettered after this, the poll must not stay QUEUED
+			h.rejectBookingRequest(ctx, req, err)
+		}
+		return err
+	})
+	subscribe(bus.SubjectBookingNew, "booking-worker", nats.BookingHandler(h.blobs, h.mailer))
+	subscribe(bus.SubjectEventEdit, "edit-event-worker", nats.EditEventHandler(h.mailer))
+	subscribe(bus.SubjectWaitlistOffer, "waitlist-worker", nats.WaitlistHandler(h.mailer))
+	subscribe(bus.SubjectEventReminder, "reminder-worker", nats.ReminderHandler(h.mailer))
+	subscribe(bus.SubjectEventCancelled, "event-cancelled-worker", nats.EventCancelledHandler(h.mailer))
+	subscribe(bus.SubjectEventNew, "new-event-worker", nats.NewEventHandler(h.mailer))
+	subscribe(bus.SubjectDigest, "digest-worker", nats.DigestHandler(h.mailer))
+	subscribe(bus.SubjectBookingRefund, "refund-worker", nats.RefundHandler(refund.NewProcessor(h.db, h.payments, h.mailer).Process))
+}
+
+// addBookingMessage puts the ticket pdf & notification payload in the
+// outbox of tx, the outbox relay publishes it to nats (email/sms)
+func addBookingMessage(ctx context.Context, tx *sql.Tx, msgID string, pdfCont *models.PDFContent) error {
+	p, err := json.Marshal(pdfCont)
+	if err != nil {
+		return err
+	}
+	return outbox.Add(ctx, tx, bus.SubjectBookingNew, msgID, p)
+}
+
+// setTicketToken signs the check-in token printed as QR code on the
+// ticket, the ticket is still sent (without QR) if signing fails
+func setTicketToken(p *models.PDFContent, eventID int64) {
+	token, err := ticket.Sign(int64(p.BookingID), eventID)
+	if err != nil {
+		log.Printf("failed to sign ticket of booking %d: %v", p.BookingID, err)
+		return
+	}
+	p.TicketToken = token
+}
+
 func newPdfContent(bookingID, userID int, userName, userEmail, eventName, eventDateTime string, seatsBooked int) *models.PDFContent {
 	eventTime, err := time.Parse(time.RFC3339, eventDateTime) // string to time.Time
 	if err != nil {
@@ -1793,36 +6578,3 @@ func newEventCache(eid int, ename string, ekey string, edate time.Time, oid int,
 		City:             ecity,
 	}
 }
-
-// helper function to connect to db.
-func connectDb() (*sql.DB, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return nil, fmt.Errorf("error loading .env file: %v", err)
-	}
-
-	cfg := mysql.NewConfig()
-	cfg.User = os.Getenv("DBUSER")
-	cfg.Passwd = os.Getenv("DBPASS")
-	cfg.Net = "tcp"
-	cfg.Addr = "127.0.0.1:3306"
-	cfg.DBName = "eventBooking"
-	cfg.ParseTime = true
-
-	db, err := sql.Open("mysql", cfg.FormatDSN())
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %v", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("error pinging database: %v", err)
-	}
-
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
-
-	log.Println("Connected to SQL Database!")
-
-	return db, nil
-}
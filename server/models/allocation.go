@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// booking request queued on NATS for the booking allocator, used for
+// hot events instead of locking the event row per http request
+type AsyncBookingRequest struct {
+	RequestID string    `json:"request_id"`
+	EventID   int64     `json:"event_id"`
+	UserID    int64     `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	UserEmail string    `json:"user_email"`
+	Seats     int64     `json:"seats"`
+	TierID    int64     `json:"tier_id"`
+	QueuedAt  time.Time `json:"queued_at"`
+}
+
+// outcome of a queued booking request, polled by the client
+type AsyncBookingStatus struct {
+	RequestID        string     `json:"request_id"`
+	EventID          int64      `json:"event_id"`
+	UserID           int64      `json:"user_id"`
+	Status           string     `json:"status"` // QUEUED, CONFIRMED or REJECTED
+	Reason           string     `json:"reason,omitempty"`
+	BookingID        *int64     `json:"booking_id,omitempty"`
+	BookingStatus    string     `json:"booking_status,omitempty"` // PENDING_PAYMENT for paid tiers
+	Amount           int64      `json:"amount,omitempty"`
+	Currency         string     `json:"currency,omitempty"`
+	PaymentExpiresAt *time.Time `json:"payment_expires_at,omitempty"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+const (
+	AsyncBookingQueued    = "QUEUED"
+	AsyncBookingConfirmed = "CONFIRMED"
+	AsyncBookingRejected  = "REJECTED"
+)
@@ -8,7 +8,17 @@ type Booking struct {
 	EventId  int64     `json:"event_id" db:"event_id"`
 	UserId   int64     `json:"user_id" db:"user_id"`
 	Seats    int64     `json:"seats" db:"seats"`
+	TierId   *int64    `json:"tier_id,omitempty" db:"tier_id"`
+	Amount   int64     `json:"amount" db:"amount"`
+	Currency string    `json:"currency" db:"currency"`
+	Status   string    `json:"status" db:"status"`
 	BookedAt time.Time `json:"booked_at" db:"booked_at"`
+
+	PaymentIntentID  *string    `json:"payment_intent_id,omitempty" db:"payment_intent_id"`
+	PaymentExpiresAt *time.Time `json:"payment_expires_at,omitempty" db:"payment_expires_at"`
+	PaidAt           *time.Time `json:"paid_at,omitempty" db:"paid_at"`
+	CheckedInAt      *time.Time `json:"checked_in_at,omitempty" db:"checked_in_at"`
+	CheckedInGate    *string    `json:"checked_in_gate,omitempty" db:"checked_in_gate"`
 }
 
 // incoming client format
@@ -20,6 +30,21 @@ type BookingRequest struct {
 	EventName string `json:"event_name"`
 	DateTime  string `json:"date_time"`
 	Seats     int64  `json:"seats"`
+	TierID    int64  `json:"tier_id"` // required when the event has ticket tiers
+}
+
+// incoming client format for cancelling, seats = 0 cancels the whole booking
+type CancelBookingRequest struct {
+	Seats int64 `json:"seats"`
+}
+
+// db level, one row per (partial) cancellation of a booking
+type BookingCancellation struct {
+	Id             int64     `json:"id" db:"id"`
+	BookingId      int64     `json:"booking_id" db:"booking_id"`
+	Seats          int64     `json:"seats" db:"seats"`
+	RemainingSeats int64     `json:"remaining_seats" db:"remaining_seats"`
+	CancelledAt    time.Time `json:"cancelled_at" db:"cancelled_at"`
 }
 
 // profile section etc
@@ -30,6 +55,9 @@ type UserBookings struct {
 	Status string `json:"status"`
 	UserId    int64     `json:"user_id"`
 	BookedAt  time.Time `json:"booked_at"`
+	TierName  string    `json:"tier_name,omitempty"`
+	Amount    int64     `json:"amount"`
+	Currency  string    `json:"currency"`
 	EventName string    `json:"name"`
 	Date      time.Time `json:"date"`
 	City      string    `json:"city"`
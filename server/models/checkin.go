@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// gates are stored in booking.checked_in_gate VARCHAR(50)
+const MaxGateLength = 50
+
+// ValidGate checks the gate (or device id) a scan is recorded under,
+// empty is allowed
+func ValidGate(gate string) error {
+	if utf8.RuneCountInString(gate) > MaxGateLength {
+		return fmt.Errorf("gate must be at most %d characters", MaxGateLength)
+	}
+	if gate != strings.TrimSpace(gate) {
+		return fmt.Errorf("gate can't start or end with spaces")
+	}
+	return nil
+}
+
+// incoming client format, token is read from the ticket QR code
+type CheckInRequest struct {
+	Token string `json:"token" binding:"required"`
+	Gate  string `json:"gate"`
+}
+
+// returned to door staff after a successful scan
+type CheckInResponse struct {
+	BookingID    int64     `json:"booking_id"`
+	AttendeeName string    `json:"attendee_name"`
+	Seats        int64     `json:"seats"`
+	TierName     string    `json:"tier_name,omitempty"`
+	CheckedInAt  time.Time `json:"checked_in_at"`
+}
+
+// one booking in the offline attendee manifest
+type ManifestEntry struct {
+	BookingID   int64      `json:"booking_id"`
+	TokenHash   string     `json:"token_hash"` // sha256 of the ticket token
+	Seats       int64      `json:"seats"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// downloaded by door staff before the event, scans are checked against it
+// while offline
+type Manifest struct {
+	EventID     int64           `json:"event_id"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Entries     []ManifestEntry `json:"entries"`
+}
+
+// a ticket scanned while offline
+type CheckInScan struct {
+	Token     string    `json:"token"`
+	Gate      string    `json:"gate"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// incoming client format for uploading offline scans
+type CheckInSyncRequest struct {
+	DeviceID string        `json:"device_id"`
+	Scans    []CheckInScan `json:"scans" binding:"required"`
+}
+
+const (
+	ConflictInvalidToken     = "INVALID_TOKEN"
+	ConflictNotFound         = "NOT_FOUND"
+	ConflictCancelled        = "CANCELLED"          // cancelled/expired, maybe after the manifest was downloaded
+	ConflictAlreadyCheckedIn = "ALREADY_CHECKED_IN" // scanned earlier at another gate or online
+	ConflictDuplicateScan    = "DUPLICATE_SCAN"     // same ticket more than once in the upload
+)
+
+// scan that could not be applied as is
+type CheckInConflict struct {
+	BookingID     int64      `json:"booking_id,omitempty"`
+	Token         string     `json:"token"`
+	Gate          string     `json:"gate"`
+	ScannedAt     time.Time  `json:"scanned_at"`
+	Reason        string     `json:"reason"`
+	CheckedInAt   *time.Time `json:"checked_in_at,omitempty"`
+	CheckedInGate string     `json:"checked_in_gate,omitempty"`
+}
+
+type CheckInSyncResult struct {
+	Accepted  int               `json:"accepted"`
+	Conflicts []CheckInConflict `json:"conflicts"`
+}
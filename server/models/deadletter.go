@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// message moved to the dead-letter stream after its retries ran out
+type DeadLetter struct {
+	Seq       uint64    `json:"seq"`     // sequence in the dead-letter stream
+	Subject   string    `json:"subject"` // original subject, replayed to it
+	MsgID     string    `json:"msg_id"`
+	Stream    string    `json:"stream"`
+	StreamSeq uint64    `json:"stream_seq"`
+	Consumer  string    `json:"consumer"`
+	Reason    string    `json:"reason"`
+	Attempts  uint64    `json:"attempts"`
+	FailedAt  time.Time `json:"failed_at"`
+	Payload   string    `json:"payload"`
+}
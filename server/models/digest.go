@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// db level, weekly digest settings of a user
+type DigestSettings struct {
+	UserId     int64      `json:"user_id" db:"user_id"`
+	OptedOut   bool       `json:"opted_out" db:"opted_out"`
+	City       *string    `json:"city,omitempty" db:"city"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty" db:"last_sent_at"`
+}
+
+// incoming client format for the digest settings, nil fields are kept
+type DigestSettingsRequest struct {
+	OptedOut *bool   `json:"opted_out"`
+	City     *string `json:"city"`
+}
+
+type DigestEvent struct {
+	EventID     int64     `json:"event_id"`
+	EventName   string    `json:"event_name"`
+	EventDate   time.Time `json:"event_date"`
+	City        string    `json:"city"`
+	OrganizedBy string    `json:"organized_by"`
+}
+
+// NATS payload of the weekly digest of one user
+type DigestPayload struct {
+	UserID         int64         `json:"user_id"`
+	UserName       string        `json:"user_name"`
+	UserEmail      string        `json:"user_email"`
+	Period         string        `json:"period"` // monday of the week, 2006-01-02
+	City           string        `json:"city,omitempty"`
+	Following      []DigestEvent `json:"following"`
+	InCity         []DigestEvent `json:"in_city"`
+	UnsubscribeURL string        `json:"unsubscribe_url,omitempty"`
+}
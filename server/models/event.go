@@ -1,10 +1,20 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrSeatsPerBooking = errors.New("too many seats for one booking")
+	ErrUserLimit       = errors.New("booking limit reached for this event")
+)
 
 type Event struct {
 	Id             int64     `json:"id" db:"id"`
 	Name           string    `json:"name" db:"name"`
+	Description    string    `json:"description" db:"description"`
 	OrgId          int64     `json:"org_id" db:"org_id"`
 	OrganizedBy    string    `json:"organized_by" db:"organized_by"`
 	Key            string    `json:"key" db:"image_key"`
@@ -16,7 +26,65 @@ type Event struct {
 	City           string    `json:"city" db:"city"`
 	State          string    `json:"state" db:"state"`
 	Country        string    `json:"country" db:"country"`
+	Latitude       *float64  `json:"latitude,omitempty" db:"latitude"`
+	Longitude      *float64  `json:"longitude,omitempty" db:"longitude"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	BookingLimits
+	EventTags
+}
+
+// per user caps set by the organizer against scalping, nil means no limit
+type BookingLimits struct {
+	MaxSeatsPerBooking *int64 `json:"max_seats_per_booking,omitempty" db:"max_seats_per_booking"`
+	MaxSeatsPerUser    *int64 `json:"max_seats_per_user,omitempty" db:"max_seats_per_user"`
+	MaxBookingsPerUser *int64 `json:"max_bookings_per_user,omitempty" db:"max_bookings_per_user"`
+}
+
+// Validate checks the limits make sense together
+func (l BookingLimits) Validate() error {
+	for _, v := range []*int64{l.MaxSeatsPerBooking, l.MaxSeatsPerUser, l.MaxBookingsPerUser} {
+		if v != nil && *v <= 0 {
+			return errors.New("booking limits must be > 0")
+		}
+	}
+	if l.MaxSeatsPerBooking != nil && l.MaxSeatsPerUser != nil && *l.MaxSeatsPerBooking > *l.MaxSeatsPerUser {
+		return errors.New("max_seats_per_booking cannot be more than max_seats_per_user")
+	}
+	return nil
+}
+
+// Check reports if a user who already has userSeats seats in
+// userBookings bookings (holds included) may book seats more
+func (l BookingLimits) Check(seats, userSeats, userBookings int64) error {
+	if l.MaxSeatsPerBooking != nil && seats > *l.MaxSeatsPerBooking {
+		return fmt.Errorf("%w: at most %d seats per booking", ErrSeatsPerBooking, *l.MaxSeatsPerBooking)
+	}
+	if l.MaxSeatsPerUser != nil && userSeats+seats > *l.MaxSeatsPerUser {
+		return fmt.Errorf("%w: at most %d seats per user, you already have %d", ErrUserLimit, *l.MaxSeatsPerUser, userSeats)
+	}
+	if l.MaxBookingsPerUser != nil && userBookings+1 > *l.MaxBookingsPerUser {
+		return fmt.Errorf("%w: at most %d bookings per user", ErrUserLimit, *l.MaxBookingsPerUser)
+	}
+	return nil
+}
+
+// Merge applies the limits sent on an event update to l, a limit left
+// out is kept and 0 removes it
+func (l BookingLimits) Merge(update BookingLimits) BookingLimits {
+	merge := func(old, v *int64) *int64 {
+		if v == nil {
+			return old
+		}
+		if *v == 0 {
+			return nil
+		}
+		return v
+	}
+	return BookingLimits{
+		MaxSeatsPerBooking: merge(l.MaxSeatsPerBooking, update.MaxSeatsPerBooking),
+		MaxSeatsPerUser:    merge(l.MaxSeatsPerUser, update.MaxSeatsPerUser),
+		MaxBookingsPerUser: merge(l.MaxBookingsPerUser, update.MaxBookingsPerUser),
+	}
 }
 
 type EventResponse struct {
@@ -27,6 +95,9 @@ type EventResponse struct {
 	OrganizationName string    `json:"organized_by"`
 	EventDate        time.Time `json:"date"`
 	City             string    `json:"city"`
+	WaitlistCount    *int      `json:"waitlist_count,omitempty"` // organizer listing only
+	DistanceKm       *float64  `json:"distance_km,omitempty"`    // near me only
+	EventTags
 }
 
 type EventCache struct {
@@ -37,18 +108,26 @@ type EventCache struct {
 	City             string    `json:"city" db:"city"`
 	OrganizationID   int       `json:"org_id" db:"org_id"`
 	OrganizationName string    `json:"organized_by" db:"organized_by"`
+	EventTags
 	// ImageUrl string
 }
 
 type UpdateEventRequest struct {
-	Name     string    `json:"name"`
-	DateTime time.Time `json:"date_time"`
-	Address  string    `json:"address"`
-	City     string    `json:"city"`
-	State    string    `json:"state"`
-	Country  string    `json:"country"`
-	Capacity int       `json:"capacity"`
-	Visible  string    `json:"visible"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description"` // left out keeps the current one
+	DateTime    time.Time `json:"date_time"`
+	Address     string    `json:"address"`
+	City        string    `json:"city"`
+	State       string    `json:"state"`
+	Country     string    `json:"country"`
+	Capacity    int       `json:"capacity"`
+	Visible     string    `json:"visible"`
+	// coordinates are geocoded from the address when left out
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// limits left out are kept, 0 removes one
+	BookingLimits
+	EventTags
 }
 
 type EventChangeType string
@@ -68,6 +147,6 @@ type EventEditChange struct {
 type EventEditedPayload struct {
 	EventID  int64             `json:"event_id"`
 	Changes  []EventEditChange `json:"changes"`
-	To       []string          `json:to`
+	To       []string          `json:"to"`
 	EditedAt time.Time         `json:"edited_at"`
 }
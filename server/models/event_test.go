@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBookingLimitsCheck(t *testing.T) {
+	limit := func(n int64) *int64 { return &n }
+	perBooking := BookingLimits{MaxSeatsPerBooking: limit(4)}
+	perUser := BookingLimits{MaxSeatsPerUser: limit(6)}
+	bookings := BookingLimits{MaxBookingsPerUser: limit(2)}
+
+	// the user's hold on 2 seats, and the hold being confirmed now
+	other := SeatHold{HoldID: "h-other", UserID: 7, Seats: 2}
+	own := SeatHold{HoldID: "h-own", UserID: 7, Seats: 3}
+	someoneElse := SeatHold{HoldID: "h-else", UserID: 8, Seats: 5}
+
+	tests := []struct {
+		name     string
+		limits   BookingLimits
+		seats    int64
+		booked   int64 // seats in confirmed bookings
+		bookings int64 // confirmed bookings
+		holds    []SeatHold
+		wantErr  error
+	}{
+		{name: "no limits", seats: 100, booked: 100, bookings: 100},
+
+		{name: "per booking limit-1", limits: perBooking, seats: 3},
+		{name: "per booking at limit", limits: perBooking, seats: 4},
+		{name: "per booking limit+1", limits: perBooking, seats: 5, wantErr: ErrSeatsPerBooking},
+		{name: "per booking ignores holds", limits: perBooking, seats: 4, holds: []SeatHold{other, own}},
+
+		{name: "per user limit-1", limits: perUser, seats: 3, booked: 2},
+		{name: "per user at limit", limits: perUser, seats: 4, booked: 2},
+		{name: "per user limit+1", limits: perUser, seats: 5, booked: 2, wantErr: ErrUserLimit},
+		{name: "per user limit-1 with hold", limits: perUser, seats: 1, booked: 2, holds: []SeatHold{other}},
+		{name: "per user at limit with hold", limits: perUser, seats: 2, booked: 2, holds: []SeatHold{other}},
+		{name: "per user limit+1 with hold", limits: perUser, seats: 3, booked: 2, holds: []SeatHold{other}, wantErr: ErrUserLimit},
+		{name: "per user own hold not counted", limits: perUser, seats: 3, booked: 3, holds: []SeatHold{own}},
+		{name: "per user limit+1 own hold not counted", limits: perUser, seats: 4, booked: 3, holds: []SeatHold{own}, wantErr: ErrUserLimit},
+		{name: "per user other users' holds not counted", limits: perUser, seats: 6, holds: []SeatHold{someoneElse}},
+
+		{name: "bookings limit-1", limits: bookings, seats: 1},
+		{name: "bookings at limit", limits: bookings, seats: 1, bookings: 1},
+		{name: "bookings limit+1", limits: bookings, seats: 1, bookings: 2, wantErr: ErrUserLimit},
+		{name: "bookings at limit with hold", limits: bookings, seats: 1, holds: []SeatHold{other}},
+		{name: "bookings limit+1 with hold", limits: bookings, seats: 1, bookings: 1, holds: []SeatHold{other}, wantErr: ErrUserLimit},
+		{name: "bookings own hold not counted", limits: bookings, seats: 1, bookings: 1, holds: []SeatHold{own}},
+		{name: "bookings other users' holds not counted", limits: bookings, seats: 1, bookings: 1, holds: []SeatHold{someoneElse}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heldSeats, held := HeldByUser(tt.holds, 7, own.HoldID)
+			err := tt.limits.Check(tt.seats, tt.booked+heldSeats, tt.bookings+held)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("Check = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// incoming client format for cancelling an event, reason is optional
+type CancelEventRequest struct {
+	Reason string `json:"reason"`
+}
+
+// db level, progress of an event cancellation shown to the organizer
+type EventCancellation struct {
+	EventId             int64            `json:"event_id" db:"event_id"`
+	Reason              *string          `json:"reason,omitempty" db:"reason"`
+	Status              string           `json:"status" db:"status"`
+	BookingsTotal       int64            `json:"bookings_total" db:"bookings_total"`
+	BookingsCancelled   int64            `json:"bookings_cancelled" db:"bookings_cancelled"`
+	RefundsCreated      int64            `json:"refunds_created" db:"refunds_created"`
+	NotificationsQueued int64            `json:"notifications_queued" db:"notifications_queued"`
+	StartedAt           time.Time        `json:"started_at" db:"started_at"`
+	CompletedAt         *time.Time       `json:"completed_at,omitempty" db:"completed_at"`
+	Refunds             []RefundProgress `json:"refunds,omitempty"`
+}
+
+// refunds of a cancelled event grouped by status
+type RefundProgress struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+	Amount int64  `json:"amount"`
+}
+
+// NATS payload telling an attendee their event was cancelled
+type EventCancelledPayload struct {
+	EventID      int64     `json:"event_id"`
+	BookingID    int64     `json:"booking_id"`
+	EventName    string    `json:"event_name"`
+	EventDate    time.Time `json:"event_date"`
+	Reason       string    `json:"reason"`
+	UserName     string    `json:"user_name"`
+	UserEmail    string    `json:"user_email"`
+	Seats        int64     `json:"seats"`
+	RefundAmount int64     `json:"refund_amount"` // 0 when nothing was paid
+	Currency     string    `json:"currency"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// seat hold, lives in redis until confirmed or expired
+type SeatHold struct {
+	HoldID    string    `json:"hold_id"`
+	EventID   int64     `json:"event_id"`
+	UserID    int64     `json:"user_id"`
+	TierID    int64     `json:"tier_id,omitempty"`
+	Seats     int64     `json:"seats"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HeldByUser sums the seats & holds userID has among holds, leaving out
+// ownHoldID: the hold being confirmed is the booking being checked
+func HeldByUser(holds []SeatHold, userID int64, ownHoldID string) (seats, count int64) {
+	for _, h := range holds {
+		if h.UserID != userID || (ownHoldID != "" && h.HoldID == ownHoldID) {
+			continue
+		}
+		seats += h.Seats
+		count++
+	}
+	return seats, count
+}
+
+// incoming client format for placing a hold
+type HoldRequest struct {
+	Seats  int64 `json:"seats"`
+	TierID int64 `json:"tier_id"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestHeldByUser(t *testing.T) {
+	holds := []SeatHold{
+		{HoldID: "a", UserID: 1, Seats: 2},
+		{HoldID: "b", UserID: 1, Seats: 3},
+		{HoldID: "c", UserID: 2, Seats: 4},
+	}
+
+	tests := []struct {
+		name      string
+		user      int64
+		own       string
+		wantSeats int64
+		wantCount int64
+	}{
+		{name: "all of the user's holds", user: 1, wantSeats: 5, wantCount: 2},
+		{name: "own hold left out", user: 1, own: "b", wantSeats: 2, wantCount: 1},
+		{name: "someone else's hold id", user: 1, own: "c", wantSeats: 5, wantCount: 2},
+		{name: "no holds", user: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seats, count := HeldByUser(holds, tt.user, tt.own)
+			if seats != tt.wantSeats || count != tt.wantCount {
+				t.Errorf("HeldByUser = %d seats in %d holds, want %d in %d", seats, count, tt.wantSeats, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// db level, stored response for a request sent with an Idempotency-Key
+type IdempotencyRecord struct {
+	Id          int64     `json:"id" db:"id"`
+	UserId      int64     `json:"user_id" db:"user_id"`
+	Key         string    `json:"idem_key" db:"idem_key"`
+	RequestHash string    `json:"request_hash" db:"request_hash"`
+	StatusCode  int       `json:"status_code" db:"status_code"`
+	Response    []byte    `json:"response" db:"response"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
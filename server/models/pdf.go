@@ -10,4 +10,11 @@ type PDFContent struct {
 	EventName     string
 	EventDateTime time.Time
 	SeatsBooked   int
+	TierName      string
+	Amount        int64 // minor units
+	Currency      string
+	// set when the ticket is re-issued after a partial cancellation
+	SeatsCancelled int
+	// signed check-in token, printed as a QR code
+	TicketToken string
 }
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// db level, refund rules set by the organizer of an event
+type RefundPolicy struct {
+	EventId              int64     `json:"event_id" db:"event_id"`
+	FullRefundDays       int       `json:"full_refund_days" db:"full_refund_days"`             // full refund until this many days before the event
+	PartialRefundPercent int       `json:"partial_refund_percent" db:"partial_refund_percent"` // refund after that
+	NoRefundHours        int       `json:"no_refund_hours" db:"no_refund_hours"`               // no refund within this many hours of the event
+	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// incoming client format for setting a refund policy
+type RefundPolicyRequest struct {
+	FullRefundDays       int `json:"full_refund_days"`
+	PartialRefundPercent int `json:"partial_refund_percent"`
+	NoRefundHours        int `json:"no_refund_hours"`
+}
+
+// DefaultRefundPolicy applies to events whose organizer never set one
+func DefaultRefundPolicy(eventID int64) RefundPolicy {
+	return RefundPolicy{
+		EventId:              eventID,
+		FullRefundDays:       7,
+		PartialRefundPercent: 50,
+		NoRefundHours:        24,
+	}
+}
+
+// RefundPercent is the share of the amount paid given back when
+// cancelling at the given time
+func (p RefundPolicy) RefundPercent(eventDate, cancelledAt time.Time) int {
+	left := eventDate.Sub(cancelledAt)
+	switch {
+	case left < time.Duration(p.NoRefundHours)*time.Hour:
+		return 0
+	case left >= time.Duration(p.FullRefundDays)*24*time.Hour:
+		return 100
+	default:
+		return p.PartialRefundPercent
+	}
+}
+
+// db level, money given back for a cancellation
+type Refund struct {
+	Id              int64      `json:"id" db:"id"`
+	BookingId       int64      `json:"booking_id" db:"booking_id"`
+	CancellationId  *int64     `json:"cancellation_id,omitempty" db:"cancellation_id"`
+	PaymentIntentID string     `json:"payment_intent_id" db:"payment_intent_id"`
+	Amount          int64      `json:"amount" db:"amount"`
+	Currency        string     `json:"currency" db:"currency"`
+	Percent         int        `json:"percent" db:"percent"`
+	Reason          string     `json:"reason" db:"reason"` // CANCELLED or UNPAYABLE
+	Status          string     `json:"status" db:"status"`
+	GatewayRefundID *string    `json:"gateway_refund_id,omitempty" db:"gateway_refund_id"`
+	FailureReason   *string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// NATS payload picked up by the refund worker
+type RefundPayload struct {
+	RefundID  int64  `json:"refund_id"`
+	BookingID int64  `json:"booking_id"`
+	UserName  string `json:"user_name"`
+	UserEmail string `json:"user_email"`
+	EventName string `json:"event_name"`
+}
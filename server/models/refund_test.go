@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefundPercent(t *testing.T) {
+	event := time.Date(2026, 3, 20, 18, 0, 0, 0, time.UTC)
+	policy := RefundPolicy{FullRefundDays: 7, PartialRefundPercent: 40, NoRefundHours: 48}
+
+	tests := []struct {
+		name   string
+		policy RefundPolicy
+		before time.Duration // cancelled this long before the event
+		want   int
+	}{
+		{name: "well ahead", policy: policy, before: 30 * 24 * time.Hour, want: 100},
+		{name: "full refund boundary", policy: policy, before: 7 * 24 * time.Hour, want: 100},
+		{name: "just inside partial", policy: policy, before: 7*24*time.Hour - time.Minute, want: 40},
+		{name: "partial", policy: policy, before: 3 * 24 * time.Hour, want: 40},
+		{name: "no refund boundary", policy: policy, before: 48 * time.Hour, want: 40},
+		{name: "just inside no refund", policy: policy, before: 48*time.Hour - time.Minute, want: 0},
+		{name: "event started", policy: policy, before: -time.Hour, want: 0},
+		{name: "default policy full", policy: DefaultRefundPolicy(1), before: 8 * 24 * time.Hour, want: 100},
+		{name: "default policy partial", policy: DefaultRefundPolicy(1), before: 2 * 24 * time.Hour, want: 50},
+		{name: "default policy none", policy: DefaultRefundPolicy(1), before: 12 * time.Hour, want: 0},
+		{name: "always full", policy: RefundPolicy{}, before: time.Minute, want: 100},
+		{name: "never", policy: RefundPolicy{FullRefundDays: 3650, NoRefundHours: 3650 * 24}, before: 365 * 24 * time.Hour, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.RefundPercent(event, event.Add(-tt.before)); got != tt.want {
+				t.Errorf("RefundPercent = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
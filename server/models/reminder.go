@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// NATS payload of a reminder mail sent before the event
+type ReminderPayload struct {
+	ReminderID  int64     `json:"reminder_id"`
+	BookingID   int64     `json:"booking_id"`
+	EventID     int64     `json:"event_id"`
+	EventName   string    `json:"event_name"`
+	EventDate   time.Time `json:"event_date"`
+	Location    string    `json:"location"`
+	UserName    string    `json:"user_name"`
+	UserEmail   string    `json:"user_email"`
+	Seats       int64     `json:"seats"`
+	HoursBefore int64     `json:"hours_before"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// position after the last result of a search page, sent back to the
+// client base64 encoded
+type SearchCursor struct {
+	Date  time.Time `json:"d"`
+	Score float64   `json:"s,omitempty"` // relevance sort only
+	ID    int64     `json:"i"`
+}
+
+type SearchFacet struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// counts of the whole result set, only computed for the first page
+type SearchFacets struct {
+	Total      int64         `json:"total"`
+	Cities     []SearchFacet `json:"cities"`
+	Categories []SearchFacet `json:"categories"`
+	Months     []SearchFacet `json:"months"` // 2006-01
+}
+
+// a page of search results as kept in redis (without image URLs)
+type EventSearchCache struct {
+	Events     []EventCache  `json:"events"`
+	Facets     *SearchFacets `json:"facets,omitempty"`
+	HasNext    bool          `json:"has_next"`
+	NextCursor string        `json:"next_cursor"`
+}
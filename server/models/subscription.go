@@ -2,10 +2,36 @@ package models
 
 import "time"
 
+// db level, a user following an organization
 type Subscription struct {
-	Id         int64     `json:"id" db:"id"`
-	UserId     int64     `json:"user_id" db:"user_id"`
-	OrgId      int64     `json:"org_id" db:"org_id"`
-	Subscribed bool      `json:"subscribed" db:"subscribed"`
-	Subscribed_at time.Time `json:"subscribed_at" db:"subscribed_at"`
+	Id           int64     `json:"id" db:"id"`
+	UserId       int64     `json:"user_id" db:"user_id"`
+	OrgId        int64     `json:"org_id" db:"org_id"`
+	SubscribedAt time.Time `json:"subscribed_at" db:"subscribed_at"`
+}
+
+// organization in the list of the ones a user follows
+type FollowedOrganization struct {
+	OrgId        int64     `json:"org_id"`
+	OrgName      string    `json:"org_name"`
+	Description  string    `json:"description"`
+	SubscribedAt time.Time `json:"subscribed_at"`
+}
+
+type Follower struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+// NATS payload telling a follower of an organization about its new
+// public event, one message per follower
+type NewEventPayload struct {
+	EventID   int64     `json:"event_id"`
+	EventName string    `json:"event_name"`
+	EventDate time.Time `json:"event_date"`
+	City      string    `json:"city"`
+	OrgID     int64     `json:"org_id"`
+	OrgName   string    `json:"org_name"`
+	To        Follower  `json:"to"`
 }
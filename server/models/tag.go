@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Categories is the curated list events are browsed by, tags are free-form
+var Categories = []string{
+	"music", "tech", "comedy", "sports", "theatre", "art",
+	"food", "business", "education", "workshop", "festival", "community",
+}
+
+const (
+	MaxEventCategories = 3
+	MaxEventTags       = 10
+	maxTagLength       = 30
+)
+
+var ErrInvalidTag = errors.New("tags may only contain a-z, 0-9, spaces and dashes")
+
+// EventTags classify an event. On update a nil list keeps what the event
+// has, an empty one clears it.
+type EventTags struct {
+	Categories []string `json:"categories,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// Normalize cleans & dedups the names and checks them against the
+// curated categories and the limits
+func (t *EventTags) Normalize() error {
+	categories, err := normalizeTags(t.Categories, MaxEventCategories)
+	if err != nil {
+		return fmt.Errorf("categories: %w", err)
+	}
+	for _, c := range categories {
+		if !IsCategory(c) {
+			return fmt.Errorf("unknown category %q", c)
+		}
+	}
+
+	tags, err := normalizeTags(t.Tags, MaxEventTags)
+	if err != nil {
+		return fmt.Errorf("tags: %w", err)
+	}
+
+	t.Categories, t.Tags = categories, tags
+	return nil
+}
+
+func normalizeTags(names []string, max int) ([]string, error) {
+	if names == nil {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		n, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	if len(out) > max {
+		return nil, fmt.Errorf("at most %d allowed", max)
+	}
+	return out, nil
+}
+
+// NormalizeTag lowercases a name and joins its words with dashes,
+// "Stand Up" and "stand-up" are the same tag
+func NormalizeTag(name string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	})
+	n := strings.Join(words, "-")
+	if n == "" || len(n) > maxTagLength {
+		return "", fmt.Errorf("tag must be 1 to %d characters", maxTagLength)
+	}
+	for _, r := range n {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return "", ErrInvalidTag
+		}
+	}
+	return n, nil
+}
+
+func IsCategory(name string) bool {
+	for _, c := range Categories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
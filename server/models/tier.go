@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// db level, ticket type of an event i.e General, VIP, Early Bird
+type TicketTier struct {
+	Id             int64      `json:"id" db:"id"`
+	EventId        int64      `json:"event_id" db:"event_id"`
+	Name           string     `json:"name" db:"name"`
+	Price          int64      `json:"price" db:"price"` // minor units i.e paise/cents
+	Currency       string     `json:"currency" db:"currency"`
+	Capacity       int64      `json:"capacity" db:"capacity"`
+	SeatsAvailable int64      `json:"seats_available" db:"seats_available"`
+	SaleStartsAt   *time.Time `json:"sale_starts_at,omitempty" db:"sale_starts_at"`
+	SaleEndsAt     *time.Time `json:"sale_ends_at,omitempty" db:"sale_ends_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// incoming client format for creating a tier
+type TicketTierRequest struct {
+	Name         string     `json:"name" binding:"required"`
+	Price        int64      `json:"price"`
+	Currency     string     `json:"currency"`
+	Capacity     int64      `json:"capacity" binding:"required"`
+	SaleStartsAt *time.Time `json:"sale_starts_at"`
+	SaleEndsAt   *time.Time `json:"sale_ends_at"`
+}
+
+// FormatAmount renders minor units as "INR 499.00", zero is "Free"
+func FormatAmount(amount int64, currency string) string {
+	if amount == 0 {
+		return "Free"
+	}
+	return fmt.Sprintf("%s %d.%02d", currency, amount/100, amount%100)
+}
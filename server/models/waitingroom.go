@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// virtual waiting room of an event, kept in redis only
+type WaitingRoomConfig struct {
+	EventID         int64     `json:"event_id"`
+	Active          bool      `json:"active"`
+	AdmitPerMinute  int64     `json:"admit_per_minute"`  // batch of users let in every minute
+	TokenTTLSeconds int64     `json:"token_ttl_seconds"` // how long an admitted user may book
+	StartsAt        time.Time `json:"starts_at"`         // admission begins, usually the on-sale time
+}
+
+// AdmittedUpTo is the last queue number let in by now, a new batch of
+// AdmitPerMinute users is admitted every minute from StartsAt
+func (c WaitingRoomConfig) AdmittedUpTo(now time.Time) int64 {
+	if now.Before(c.StartsAt) {
+		return 0
+	}
+	minutes := int64(now.Sub(c.StartsAt) / time.Minute)
+	return (minutes + 1) * c.AdmitPerMinute
+}
+
+// TokenTTL is how long an admission token stays valid after it is issued
+func (c WaitingRoomConfig) TokenTTL() time.Duration {
+	return time.Duration(c.TokenTTLSeconds) * time.Second
+}
+
+// incoming client format for turning the waiting room on/off
+type WaitingRoomRequest struct {
+	Active          bool       `json:"active"`
+	AdmitPerMinute  int64      `json:"admit_per_minute"`
+	TokenTTLSeconds int64      `json:"token_ttl_seconds"`
+	StartsAt        *time.Time `json:"starts_at"`
+}
+
+// returned to a user polling the waiting room
+type WaitingRoomStatus struct {
+	EventID        int64      `json:"event_id"`
+	QueueNumber    int64      `json:"queue_number"`
+	Ahead          int64      `json:"ahead"` // users still waiting in front
+	Admitted       bool       `json:"admitted"`
+	Token          string     `json:"admission_token,omitempty"`
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty"`
+}
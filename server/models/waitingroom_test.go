@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdmittedUpTo(t *testing.T) {
+	start := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	cfg := WaitingRoomConfig{AdmitPerMinute: 50, StartsAt: start}
+
+	tests := []struct {
+		name string
+		cfg  WaitingRoomConfig
+		now  time.Time
+		want int64
+	}{
+		{name: "long before start", cfg: cfg, now: start.Add(-time.Hour), want: 0},
+		{name: "just before start", cfg: cfg, now: start.Add(-time.Nanosecond), want: 0},
+		{name: "at start", cfg: cfg, now: start, want: 50},
+		{name: "end of first minute", cfg: cfg, now: start.Add(time.Minute - time.Nanosecond), want: 50},
+		{name: "second batch cutoff", cfg: cfg, now: start.Add(time.Minute), want: 100},
+		{name: "rate times elapsed", cfg: cfg, now: start.Add(10*time.Minute + 30*time.Second), want: 11 * 50},
+		{name: "a day in", cfg: cfg, now: start.Add(24 * time.Hour), want: (24*60 + 1) * 50},
+		{name: "one per minute", cfg: WaitingRoomConfig{AdmitPerMinute: 1, StartsAt: start}, now: start.Add(2 * time.Minute), want: 3},
+		{name: "nobody admitted", cfg: WaitingRoomConfig{StartsAt: start}, now: start.Add(time.Hour), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.AdmittedUpTo(tt.now); got != tt.want {
+				t.Errorf("AdmittedUpTo = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenTTL(t *testing.T) {
+	tests := []struct {
+		seconds int64
+		want    time.Duration
+	}{
+		{seconds: 0, want: 0},
+		{seconds: 1, want: time.Second},
+		{seconds: 600, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		cfg := WaitingRoomConfig{TokenTTLSeconds: tt.seconds}
+		if got := cfg.TokenTTL(); got != tt.want {
+			t.Errorf("TokenTTL(%d s) = %v, want %v", tt.seconds, got, tt.want)
+		}
+	}
+}
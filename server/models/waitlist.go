@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// db level
+type WaitlistEntry struct {
+	Id             int64      `json:"id" db:"id"`
+	EventId        int64      `json:"event_id" db:"event_id"`
+	UserId         int64      `json:"user_id" db:"user_id"`
+	Seats          int64      `json:"seats" db:"seats"`
+	TierId         *int64     `json:"tier_id,omitempty" db:"tier_id"`
+	Status         string     `json:"status" db:"status"`
+	HoldID         *string    `json:"hold_id,omitempty" db:"hold_id"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty" db:"offer_expires_at"`
+	JoinedAt       time.Time  `json:"joined_at" db:"joined_at"`
+}
+
+// incoming client format for joining a waitlist
+type WaitlistRequest struct {
+	Seats  int64 `json:"seats"`
+	TierID int64 `json:"tier_id"`
+}
+
+// NATS payload sent when freed seats are offered to a waiting user
+type WaitlistOfferPayload struct {
+	WaitlistID int64     `json:"waitlist_id"`
+	EventID    int64     `json:"event_id"`
+	EventName  string    `json:"event_name"`
+	EventDate  time.Time `json:"event_date"`
+	UserName   string    `json:"user_name"`
+	UserEmail  string    `json:"user_email"`
+	Seats      int64     `json:"seats"`
+	HoldID     string    `json:"hold_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,88 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cloud "github.com/yeshu2004/go-event-booking/aws"
+)
+
+// Store keeps event images & ticket pdfs, implemented by s3 and by the
+// local filesystem for running without aws
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the content & content type stored under key
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	Delete(ctx context.Context, key string) error
+	// UploadURL lets a client PUT the object itself until expires
+	UploadURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	// DownloadURL lets a client GET the object until expires
+	DownloadURL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// ReceiptKey is where the ticket pdf of a booking is kept
+func ReceiptKey(userID, bookingID int) string {
+	return fmt.Sprintf("receipt/user-%d/booking_%d.pdf", userID, bookingID)
+}
+
+// EventImageKey is where an organization uploads the image of an event,
+// the file name sent by the client can't lead out of the org's folder
+func EventImageKey(orgID int64, fileName string) (string, error) {
+	if strings.ContainsAny(fileName, "/\\") {
+		return "", ErrInvalidKey
+	}
+	key := fmt.Sprintf("events/uploads/%d/%s", orgID, fileName)
+	if err := ValidKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// IsEventImageKey reports if key is a valid event image key of the
+// organization, i.e. one EventImageKey could have returned
+func IsEventImageKey(orgID int64, key string) bool {
+	return strings.HasPrefix(key, fmt.Sprintf("events/uploads/%d/", orgID)) && ValidKey(key) == nil
+}
+
+// ValidKey returns ErrInvalidKey unless key is already clean: not empty,
+// no leading, trailing or double slashes, no "." or ".." segments
+func ValidKey(key string) error {
+	clean, err := cleanKey(key)
+	if err != nil || clean != key {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// NewStoreFromEnv returns the store picked by BLOB_STORE, defaults to s3
+func NewStoreFromEnv() (Store, error) {
+	switch name := os.Getenv("BLOB_STORE"); name {
+	case "", "s3":
+		bucket := os.Getenv("S3_BUCKET")
+		if bucket == "" {
+			bucket = "ticket-one"
+		}
+		return NewS3Store(cloud.NewS3Service(cloud.LoadAwsConifg()), bucket), nil
+	case "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "blobs"
+		}
+		baseURL := os.Getenv("BLOB_PUBLIC_URL")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		return NewLocal(dir, baseURL, os.Getenv("BLOB_SECRET"))
+	default:
+		return nil, fmt.Errorf("unknown blob store: %q", name)
+	}
+}
@@ -0,0 +1,169 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OpGet = "GET"
+	OpPut = "PUT"
+
+	// route of the api serving the signed urls
+	LocalRoute = "/api/blob/"
+)
+
+var (
+	ErrNoSecret  = errors.New("BLOB_SECRET is not set")
+	ErrBadSigned = errors.New("invalid or expired blob url")
+)
+
+// Local keeps blobs as files under dir, the signed urls point to the api
+// (LocalRoute) which checks them with Verify. Processes sharing dir &
+// secret (api + workers) can hand out each other's urls.
+type Local struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+func NewLocal(dir, baseURL, secret string) (*Local, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), secret: []byte(secret)}, nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// readers never see a half written file
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get returns the file of key, the content type comes from its extension
+func (l *Local) Get(ctx context.Context, key string) ([]byte, string, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return data, contentType, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) UploadURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return l.signedURL(OpPut, key, expires)
+}
+
+func (l *Local) DownloadURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return l.signedURL(OpGet, key, expires)
+}
+
+// Verify checks the exp & sig query params of a signed url for op on key
+func (l *Local) Verify(op, key, exp, sig string) error {
+	expAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expAt {
+		return ErrBadSigned
+	}
+	want := l.sign(op, key, expAt)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return ErrBadSigned
+	}
+	return nil
+}
+
+func (l *Local) signedURL(op, key string, expires time.Duration) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	exp := time.Now().Add(expires).Unix()
+	segments := strings.Split(clean, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	q := url.Values{}
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", l.sign(op, clean, exp))
+	return fmt.Sprintf("%s%s%s?%s", l.baseURL, LocalRoute, strings.Join(segments, "/"), q.Encode()), nil
+}
+
+func (l *Local) sign(op, key string, exp int64) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", op, key, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// path maps key to its file, keys can't point outside dir
+func (l *Local) path(key string) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clean)), nil
+}
+
+func cleanKey(key string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if clean == "" || clean != strings.TrimPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	return clean, nil
+}
@@ -0,0 +1,49 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	cloud "github.com/yeshu2004/go-event-booking/aws"
+)
+
+// S3Store keeps blobs in one s3 bucket
+type S3Store struct {
+	svc    *cloud.S3Service
+	bucket string
+}
+
+func NewS3Store(svc *cloud.S3Service, bucket string) *S3Store {
+	return &S3Store{svc: svc, bucket: bucket}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return s.svc.UploadObject(ctx, s.bucket, key, data, aws.String(contentType))
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, string, error) {
+	data, contentType, err := s.svc.GetObject(ctx, s.bucket, key)
+	if err != nil {
+		var noKey *types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.svc.DeleteObject(ctx, s.bucket, key)
+}
+
+func (s *S3Store) UploadURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.svc.GetPresignUploadURL(ctx, s.bucket, key, expires)
+}
+
+func (s *S3Store) DownloadURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.svc.GetPresignDownloadURL(ctx, s.bucket, key, int(expires/time.Minute))
+}
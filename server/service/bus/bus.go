@@ -0,0 +1,103 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Message is what a Handler gets, Attempt is 1 on the first delivery
+type Message struct {
+	Subject string
+	ID      string
+	Data    []byte
+	Attempt uint64
+}
+
+// Handler processes a message, returning nil acks it while an error has
+// it redelivered with backoff (and dead-lettered once retries run out)
+type Handler func(ctx context.Context, msg Message) error
+
+type Publisher interface {
+	// Publish sends payload on subject, a msgID already published is
+	// dropped so retried publishes are safe
+	Publish(ctx context.Context, subject, msgID string, payload []byte) error
+}
+
+type Subscriber interface {
+	// Subscribe hands messages of subject to h until ctx is done.
+	// Subscribers with the same durable name share the messages, every
+	// durable gets all of them.
+	Subscribe(ctx context.Context, subject, durable string, h Handler) error
+}
+
+// Bus is implemented by the nats jetstream client and by Memory
+type Bus interface {
+	Publisher
+	Subscriber
+}
+
+// subjects of the messages sent between the api and the workers
+const (
+	SubjectBookingNew     = "BOOKING.new"
+	SubjectBookingRefund  = "BOOKING.refund"
+	SubjectEventEdit      = "EVENT.edit"
+	SubjectWaitlistOffer  = "EVENT.waitlist"
+	SubjectEventReminder  = "EVENT.reminder"
+	SubjectEventCancelled = "EVENT.cancelled"
+	SubjectEventNew       = "EVENT.new"
+	SubjectDigest         = "EVENT.digest"
+	SubjectBookingRequest = "ALLOC.request"
+)
+
+// BookingMsgID is the dedup id of a new booking message
+func BookingMsgID(bookingID int) string {
+	return fmt.Sprintf("booking-%d", bookingID)
+}
+
+// BookingReissueMsgID is the dedup id of an updated booking (after a
+// partial cancellation), sent on the same subject so the worker
+// re-generates the pdf
+func BookingReissueMsgID(bookingID int, cancellationID int64) string {
+	return fmt.Sprintf("booking-%d-cancellation-%d", bookingID, cancellationID)
+}
+
+// EditEventMsgID is the dedup id of an edit-event message
+func EditEventMsgID(eventID int, editedAt time.Time) string {
+	return fmt.Sprintf("edit-event-%d-%d", eventID, editedAt.UnixNano())
+}
+
+func WaitlistOfferMsgID(waitlistID int64, holdID string) string {
+	return fmt.Sprintf("waitlist-offer-%d-%s", waitlistID, holdID)
+}
+
+func RefundMsgID(refundID int64) string {
+	return fmt.Sprintf("refund-%d", refundID)
+}
+
+func BookingRequestMsgID(requestID string) string {
+	return "booking-request-" + requestID
+}
+
+// DigestMsgID is the dedup id of the weekly digest of a user
+func DigestMsgID(userID int64, period string) string {
+	return fmt.Sprintf("digest-%d-%s", userID, period)
+}
+
+// NewEventMsgID is the dedup id of the notice of a new event to one
+// follower
+func NewEventMsgID(eventID, userID int64) string {
+	return fmt.Sprintf("new-event-%d-user-%d", eventID, userID)
+}
+
+// EventCancelledMsgID is the dedup id of the cancellation notice of one
+// booking of a cancelled event
+func EventCancelledMsgID(eventID, bookingID int64) string {
+	return fmt.Sprintf("event-cancelled-%d-booking-%d", eventID, bookingID)
+}
+
+// ReminderMsgID is the dedup id of a reminder, a rescheduled reminder
+// gets a new one
+func ReminderMsgID(reminderID int64, sendAt time.Time) string {
+	return fmt.Sprintf("reminder-%d-%d", reminderID, sendAt.Unix())
+}
@@ -0,0 +1,203 @@
+package bus
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// MemoryConfig tunes the retries of Memory, zero values take the defaults
+type MemoryConfig struct {
+	MaxAttempts int           // deliveries before dead-lettering, default 5
+	RetryDelay  time.Duration // first retry delay, doubled per retry, default 1s
+	MaxDelay    time.Duration // default 1m
+	DedupWindow time.Duration // msg ids are remembered this long, default 2m
+}
+
+// DeadLetter is a message whose retries ran out
+type DeadLetter struct {
+	Message
+	Durable  string
+	Reason   string
+	FailedAt time.Time
+}
+
+// Memory is an in-process Bus for running the api without a nats server
+// and for tests. Like jetstream it keeps messages until a subscriber
+// acks them, retries failures with backoff and dead-letters after
+// MaxAttempts, but nothing survives a restart.
+type Memory struct {
+	cfg MemoryConfig
+
+	mu      sync.Mutex
+	groups  map[string]map[string]*memGroup // subject -> durable
+	backlog map[string][]Message            // published before any subscriber
+	seen    map[string]time.Time            // msg id -> published at
+	dead    []DeadLetter
+}
+
+func NewMemory(cfg MemoryConfig) *Memory {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Second
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = time.Minute
+	}
+	if cfg.DedupWindow <= 0 {
+		cfg.DedupWindow = 2 * time.Minute
+	}
+	return &Memory{
+		cfg:     cfg,
+		groups:  make(map[string]map[string]*memGroup),
+		backlog: make(map[string][]Message),
+		seen:    make(map[string]time.Time),
+	}
+}
+
+func (m *Memory) Publish(ctx context.Context, subject, msgID string, payload []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, at := range m.seen {
+		if now.Sub(at) > m.cfg.DedupWindow {
+			delete(m.seen, id)
+		}
+	}
+	if msgID != "" {
+		if _, dup := m.seen[msgID]; dup {
+			return nil
+		}
+		m.seen[msgID] = now
+	}
+
+	data := make([]byte, len(payload))
+	copy(data, payload)
+	msg := Message{Subject: subject, ID: msgID, Data: data}
+
+	groups := m.groups[subject]
+	if len(groups) == 0 {
+		m.backlog[subject] = append(m.backlog[subject], msg)
+		return nil
+	}
+	for _, g := range groups {
+		g.push(msg)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, subject, durable string, h Handler) error {
+	g := m.group(subject, durable)
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		msg, ok := g.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-g.notify:
+			}
+			continue
+		}
+
+		msg.Attempt++
+		if err := h(ctx, msg); err != nil {
+			m.retry(g, durable, msg, err)
+		}
+	}
+}
+
+// DeadLetters returns the messages whose retries ran out
+func (m *Memory) DeadLetters() []DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeadLetter(nil), m.dead...)
+}
+
+// group returns the queue of durable on subject, the first durable of a
+// subject takes over what was published before it subscribed
+func (m *Memory) group(subject, durable string) *memGroup {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.groups[subject] == nil {
+		m.groups[subject] = make(map[string]*memGroup)
+	}
+	g, ok := m.groups[subject][durable]
+	if !ok {
+		g = &memGroup{notify: make(chan struct{}, 1)}
+		m.groups[subject][durable] = g
+		for _, msg := range m.backlog[subject] {
+			g.push(msg)
+		}
+		delete(m.backlog, subject)
+	}
+	return g
+}
+
+func (m *Memory) retry(g *memGroup, durable string, msg Message, err error) {
+	if msg.Attempt >= uint64(m.cfg.MaxAttempts) {
+		log.Printf("dead-lettered %s message %s after %d attempts: %v", msg.Subject, msg.ID, msg.Attempt, err)
+		m.mu.Lock()
+		m.dead = append(m.dead, DeadLetter{Message: msg, Durable: durable, Reason: err.Error(), FailedAt: time.Now()})
+		m.mu.Unlock()
+		return
+	}
+
+	delay := m.cfg.RetryDelay
+	for i := uint64(1); i < msg.Attempt && delay < m.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > m.cfg.MaxDelay {
+		delay = m.cfg.MaxDelay
+	}
+	time.AfterFunc(delay, func() { g.push(msg) })
+}
+
+// memGroup is the queue shared by the subscribers of one durable
+type memGroup struct {
+	mu      sync.Mutex
+	pending []Message
+	notify  chan struct{}
+}
+
+func (g *memGroup) push(msg Message) {
+	g.mu.Lock()
+	g.pending = append(g.pending, msg)
+	g.mu.Unlock()
+	g.wake()
+}
+
+func (g *memGroup) pop() (Message, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.pending) == 0 {
+		return Message{}, false
+	}
+	msg := g.pending[0]
+	g.pending = g.pending[1:]
+	// more left, let another subscriber of the group pick it up
+	if len(g.pending) > 0 {
+		g.wake()
+	}
+	return msg, true
+}
+
+func (g *memGroup) wake() {
+	select {
+	case g.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,231 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fastRetries keeps backoff short enough for tests
+var fastRetries = MemoryConfig{MaxAttempts: 3, RetryDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// subscribe runs h on subject/durable until the test ends, it returns
+// once the durable is registered so publishes that follow reach it
+func subscribe(t *testing.T, m *Memory, subject, durable string, h Handler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := m.Subscribe(ctx, subject, durable, h); err != nil {
+			t.Errorf("subscribe %s/%s: %v", subject, durable, err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	waitFor(t, "subscription", func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		_, ok := m.groups[subject][durable]
+		return ok
+	})
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recorder collects the deliveries seen by handlers
+type recorder struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func (r *recorder) add(msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+}
+
+func (r *recorder) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.msgs)
+}
+
+func (r *recorder) all() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.msgs...)
+}
+
+func TestMemoryRetriesUntilAcked(t *testing.T) {
+	m := NewMemory(fastRetries)
+	var seen recorder
+	subscribe(t, m, SubjectBookingNew, "w", func(ctx context.Context, msg Message) error {
+		seen.add(msg)
+		if msg.Attempt < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	if err := m.Publish(context.Background(), SubjectBookingNew, "b-1", []byte("ticket")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "third delivery", func() bool { return seen.len() == 3 })
+
+	// acked on the third attempt, nothing more comes
+	time.Sleep(20 * time.Millisecond)
+	msgs := seen.all()
+	if len(msgs) != 3 {
+		t.Fatalf("got %d deliveries, want 3", len(msgs))
+	}
+	for i, msg := range msgs {
+		if msg.Attempt != uint64(i+1) || msg.ID != "b-1" || string(msg.Data) != "ticket" {
+			t.Errorf("delivery %d = %+v", i, msg)
+		}
+	}
+	if dead := m.DeadLetters(); len(dead) != 0 {
+		t.Errorf("got %d dead letters, want none", len(dead))
+	}
+}
+
+func TestMemoryDeadLettersAfterMaxAttempts(t *testing.T) {
+	m := NewMemory(fastRetries)
+	var seen recorder
+	subscribe(t, m, SubjectBookingRefund, "refund-worker", func(ctx context.Context, msg Message) error {
+		seen.add(msg)
+		return errors.New("gateway down")
+	})
+
+	if err := m.Publish(context.Background(), SubjectBookingRefund, "refund-7", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "dead letter", func() bool { return len(m.DeadLetters()) == 1 })
+
+	time.Sleep(20 * time.Millisecond)
+	if n := seen.len(); n != fastRetries.MaxAttempts {
+		t.Errorf("got %d deliveries, want %d", n, fastRetries.MaxAttempts)
+	}
+
+	dead := m.DeadLetters()[0]
+	if dead.ID != "refund-7" || dead.Durable != "refund-worker" || dead.Reason != "gateway down" || dead.Attempt != uint64(fastRetries.MaxAttempts) {
+		t.Errorf("dead letter = %+v", dead)
+	}
+}
+
+func TestMemoryDedupByMsgID(t *testing.T) {
+	tests := []struct {
+		name  string
+		ids   []string
+		wait  time.Duration // between publishes
+		wants int
+	}{
+		{name: "same id dropped", ids: []string{"a", "a", "a"}, wants: 1},
+		{name: "distinct ids kept", ids: []string{"a", "b", "c"}, wants: 3},
+		{name: "empty id never deduped", ids: []string{"", ""}, wants: 2},
+		{name: "same id after window", ids: []string{"a", "a"}, wait: 30 * time.Millisecond, wants: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(MemoryConfig{DedupWindow: 10 * time.Millisecond})
+			var seen recorder
+			subscribe(t, m, SubjectEventEdit, "w", func(ctx context.Context, msg Message) error {
+				seen.add(msg)
+				return nil
+			})
+
+			for _, id := range tt.ids {
+				if err := m.Publish(context.Background(), SubjectEventEdit, id, nil); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(tt.wait)
+			}
+			waitFor(t, "deliveries", func() bool { return seen.len() >= tt.wants })
+
+			time.Sleep(20 * time.Millisecond)
+			if n := seen.len(); n != tt.wants {
+				t.Errorf("got %d deliveries, want %d", n, tt.wants)
+			}
+		})
+	}
+}
+
+func TestMemoryDurablesShareMessages(t *testing.T) {
+	m := NewMemory(fastRetries)
+
+	// two instances of the same worker split the messages, another
+	// durable gets every one of them
+	var mu sync.Mutex
+	byID := make(map[string]int)
+	var shared, other recorder
+	worker := func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		byID[msg.ID]++
+		mu.Unlock()
+		shared.add(msg)
+		return nil
+	}
+	subscribe(t, m, SubjectEventReminder, "reminder-worker", worker)
+	subscribe(t, m, SubjectEventReminder, "reminder-worker", worker)
+	subscribe(t, m, SubjectEventReminder, "audit", func(ctx context.Context, msg Message) error {
+		other.add(msg)
+		return nil
+	})
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		if err := m.Publish(context.Background(), SubjectEventReminder, fmt.Sprintf("r-%d", i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "all deliveries", func() bool { return shared.len() == n && other.len() == n })
+
+	time.Sleep(20 * time.Millisecond)
+	if shared.len() != n || other.len() != n {
+		t.Fatalf("got %d shared & %d other deliveries, want %d each", shared.len(), other.len(), n)
+	}
+	for id, count := range byID {
+		if count != 1 {
+			t.Errorf("%s delivered %d times to the shared durable", id, count)
+		}
+	}
+}
+
+func TestMemoryKeepsBacklogUntilSubscribed(t *testing.T) {
+	m := NewMemory(fastRetries)
+	for i := 0; i < 3; i++ {
+		if err := m.Publish(context.Background(), SubjectDigest, fmt.Sprintf("d-%d", i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var seen recorder
+	subscribe(t, m, SubjectDigest, "digest-worker", func(ctx context.Context, msg Message) error {
+		seen.add(msg)
+		return nil
+	})
+	waitFor(t, "backlog", func() bool { return seen.len() == 3 })
+
+	for i, msg := range seen.all() {
+		if want := fmt.Sprintf("d-%d", i); msg.ID != want {
+			t.Errorf("delivery %d = %s, want %s in publish order", i, msg.ID, want)
+		}
+	}
+}
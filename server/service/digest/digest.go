@@ -0,0 +1,266 @@
+package digest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yeshu2004/go-event-booking/models"
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/outbox"
+)
+
+const (
+	// digests of a week go out from monday 09:00 UTC
+	sendOffset = 9 * time.Hour
+	// events coming up in this window are in the digest
+	window = 14 * 24 * time.Hour
+	// events per section of the digest
+	maxEvents = 10
+
+	periodLayout = "2006-01-02"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Job sends the weekly digest of upcoming events through the outbox.
+// Users are claimed with SKIP LOCKED and their week is recorded in the
+// tx the digest is queued in, so several jobs & reruns never double-send.
+type Job struct {
+	db        *sql.DB
+	batchSize int
+	// where the unsubscribe link points, no link without DIGEST_SECRET
+	publicURL string
+}
+
+func NewJob(db *sql.DB, batchSize int) *Job {
+	publicURL := os.Getenv("API_PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
+	return &Job{db: db, batchSize: batchSize, publicURL: strings.TrimRight(publicURL, "/")}
+}
+
+// Period is the monday (UTC) of the week t falls in
+func Period(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// Run sends the digests of the current week every interval until ctx is
+// done, nothing happens before the send time of the week
+func (j *Job) Run(ctx context.Context, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		// once per round, not per batch
+		if err := j.addUsers(ctx); err != nil {
+			log.Printf("digest job error: %v", err)
+		}
+
+		for {
+			n, err := j.SendDue(ctx, time.Now())
+			if err != nil {
+				log.Printf("digest job error: %v", err)
+			}
+			if err != nil || n < j.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("shutting down digest job...")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// addUsers gives the users without digest settings a row of defaults,
+// concurrent jobs adding the same user is fine
+func (j *Job) addUsers(ctx context.Context) error {
+	_, err := j.db.ExecContext(ctx, `INSERT IGNORE INTO user_digest (user_id)
+		SELECT u.id FROM user u LEFT JOIN user_digest d ON d.user_id = u.id WHERE d.user_id IS NULL`)
+	return err
+}
+
+// SendDue handles one batch of users still without the digest of the
+// week of now and returns how many were picked up, users need a row from
+// addUsers first
+func (j *Job) SendDue(ctx context.Context, now time.Time) (int, error) {
+	period := Period(now)
+	if now.Before(period.Add(sendOffset)) {
+		return 0, nil
+	}
+	p := period.Format(periodLayout)
+
+	tx, err := j.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT d.user_id, d.city, u.first_name, u.email FROM user_digest d
+		JOIN user u ON u.id = d.user_id
+		WHERE d.opted_out = FALSE AND (d.last_period IS NULL OR d.last_period < ?)
+		ORDER BY d.user_id LIMIT ? FOR UPDATE OF d SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, p, j.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var digests []models.DigestPayload
+	for rows.Next() {
+		var d models.DigestPayload
+		var city sql.NullString
+		if err := rows.Scan(&d.UserID, &city, &d.UserName, &d.UserEmail); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		d.City = city.String
+		d.Period = p
+		digests = append(digests, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range digests {
+		if err := j.fill(ctx, tx, &d, now); err != nil {
+			return 0, fmt.Errorf("digest of user %d: %w", d.UserID, err)
+		}
+
+		// nothing coming up, the week is still marked done but
+		// last_sent_at stays the time of the last digest sent
+		if len(d.Following) > 0 || len(d.InCity) > 0 {
+			if token, err := UnsubscribeToken(d.UserID); err == nil {
+				d.UnsubscribeURL = j.publicURL + "/api/digest/unsubscribe?token=" + url.QueryEscape(token)
+			}
+			payload, err := json.Marshal(d)
+			if err != nil {
+				return 0, err
+			}
+			if err := outbox.Add(ctx, tx, bus.SubjectDigest, bus.DigestMsgID(d.UserID, p), payload); err != nil {
+				return 0, err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE user_digest SET last_sent_at = ? WHERE user_id = ?", now, d.UserID); err != nil {
+				return 0, err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE user_digest SET last_period = ? WHERE user_id = ?", p, d.UserID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(digests), nil
+}
+
+// fill adds the upcoming public events of followed organizations and of
+// the user's city to d
+func (j *Job) fill(ctx context.Context, tx *sql.Tx, d *models.DigestPayload, now time.Time) error {
+	until := now.Add(window)
+
+	following, err := queryEvents(ctx, tx, `SELECT e.id, e.name, e.date, e.city, e.organized_by FROM event e
+		JOIN subscription s ON s.org_id = e.org_id
+		WHERE s.user_id = ? AND e.visible = 'PUBLIC' AND e.date BETWEEN ? AND ?
+		ORDER BY e.date LIMIT ?`, d.UserID, now, until, maxEvents)
+	if err != nil {
+		return err
+	}
+	d.Following = following
+
+	// no city set, go by the latest booking
+	if d.City == "" {
+		err := tx.QueryRowContext(ctx, `SELECT e.city FROM booking b JOIN event e ON e.id = b.event_id
+			WHERE b.user_id = ? ORDER BY b.booked_at DESC LIMIT 1`, d.UserID).Scan(&d.City)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	if d.City == "" {
+		return nil
+	}
+
+	// events of followed organizations are listed once
+	inCity, err := queryEvents(ctx, tx, `SELECT e.id, e.name, e.date, e.city, e.organized_by FROM event e
+		WHERE e.city = ? AND e.visible = 'PUBLIC' AND e.date BETWEEN ? AND ?
+		AND e.org_id NOT IN (SELECT org_id FROM subscription WHERE user_id = ?)
+		ORDER BY e.date LIMIT ?`, d.City, now, until, d.UserID, maxEvents)
+	if err != nil {
+		return err
+	}
+	d.InCity = inCity
+	return nil
+}
+
+func queryEvents(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]models.DigestEvent, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.DigestEvent
+	for rows.Next() {
+		var e models.DigestEvent
+		if err := rows.Scan(&e.EventID, &e.EventName, &e.EventDate, &e.City, &e.OrganizedBy); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// UnsubscribeToken signs the user id for the one click unsubscribe link
+// of the digest, so it works without logging in
+func UnsubscribeToken(userID int64) (string, error) {
+	secret := os.Getenv("DIGEST_SECRET")
+	if secret == "" {
+		return "", errors.New("DIGEST_SECRET is not set")
+	}
+	payload := strconv.FormatInt(userID, 10)
+	return payload + "." + signature([]byte(secret), payload), nil
+}
+
+// VerifyUnsubscribeToken returns the user id of a valid token
+func VerifyUnsubscribeToken(token string) (int64, error) {
+	secret := os.Getenv("DIGEST_SECRET")
+	if secret == "" {
+		return 0, ErrInvalidToken
+	}
+	payload, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || !hmac.Equal([]byte(signature([]byte(secret), payload)), []byte(sig)) {
+		return 0, ErrInvalidToken
+	}
+	userID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func signature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("digest-unsubscribe." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
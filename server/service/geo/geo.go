@@ -0,0 +1,96 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+const earthRadiusKm = 6371.0
+
+var (
+	ErrNotFound     = errors.New("address could not be geocoded")
+	ErrInvalidPoint = errors.New("latitude must be within [-90, 90] and longitude within [-180, 180]")
+)
+
+// Geocoder turns the address of an event into coordinates, implemented
+// by the geocoding provider in use (only the offline stub for now)
+type Geocoder interface {
+	Geocode(ctx context.Context, addr Address) (*Point, error)
+}
+
+type Address struct {
+	Street  string
+	City    string
+	State   string
+	Country string
+}
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func (p Point) Validate() error {
+	if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 || math.IsNaN(p.Lat) || math.IsNaN(p.Lng) {
+		return ErrInvalidPoint
+	}
+	return nil
+}
+
+// Box is a lat/lng rectangle, MinLng > MaxLng when it crosses the
+// antimeridian
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// BoundingBox returns the smallest box holding every point within
+// radiusKm of p, used as an index friendly pre-filter before the exact
+// distance check. A negative radius is taken as 0.
+func BoundingBox(p Point, radiusKm float64) Box {
+	if radiusKm < 0 {
+		radiusKm = 0
+	}
+	dLat := radiusKm / earthRadiusKm * 180 / math.Pi
+	b := Box{MinLat: p.Lat - dLat, MaxLat: p.Lat + dLat, MinLng: -180, MaxLng: 180}
+
+	// near the poles every longitude is in range
+	if b.MinLat <= -90 || b.MaxLat >= 90 {
+		b.MinLat = math.Max(b.MinLat, -90)
+		b.MaxLat = math.Min(b.MaxLat, 90)
+		return b
+	}
+
+	dLng := math.Asin(math.Sin(radiusKm/earthRadiusKm)/math.Cos(p.Lat*math.Pi/180)) * 180 / math.Pi
+	b.MinLng = wrapLng(p.Lng - dLng)
+	b.MaxLng = wrapLng(p.Lng + dLng)
+	return b
+}
+
+func wrapLng(lng float64) float64 {
+	if lng < -180 {
+		return lng + 360
+	}
+	if lng > 180 {
+		return lng - 360
+	}
+	return lng
+}
+
+// NewGeocoderFromEnv returns the geocoder picked by GEOCODER, defaults to
+// none: geocoding is off and coordinates are only set when the organizer
+// sends them. The offline stub only knows a few cities, GEOCODER=stub
+// turns it on for development.
+func NewGeocoderFromEnv() (Geocoder, error) {
+	switch name := os.Getenv("GEOCODER"); name {
+	case "", "none":
+		return nil, nil
+	case "stub":
+		return NewStub(), nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder: %q", name)
+	}
+}
@@ -0,0 +1,79 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// km spanning one degree of latitude
+const kmPerDegree = earthRadiusKm * math.Pi / 180
+
+func TestBoundingBox(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        Point
+		radiusKm float64
+		want     Box
+	}{
+		{name: "zero radius", p: Point{Lat: 10, Lng: 20}, radiusKm: 0, want: Box{MinLat: 10, MaxLat: 10, MinLng: 20, MaxLng: 20}},
+		{name: "negative radius", p: Point{Lat: 10, Lng: 20}, radiusKm: -5, want: Box{MinLat: 10, MaxLat: 10, MinLng: 20, MaxLng: 20}},
+		{name: "equator", p: Point{}, radiusKm: kmPerDegree, want: Box{MinLat: -1, MaxLat: 1, MinLng: -1, MaxLng: 1}},
+		{name: "longitude widens with latitude", p: Point{Lat: 60, Lng: 10}, radiusKm: kmPerDegree, want: Box{MinLat: 59, MaxLat: 61, MinLng: 10 - 2.0003047799145306, MaxLng: 10 + 2.0003047799145306}},
+
+		// MinLng > MaxLng, the box crosses the antimeridian
+		{name: "east of antimeridian", p: Point{Lng: 179.5}, radiusKm: kmPerDegree, want: Box{MinLat: -1, MaxLat: 1, MinLng: 178.5, MaxLng: -179.5}},
+		{name: "west of antimeridian", p: Point{Lng: -179.5}, radiusKm: kmPerDegree, want: Box{MinLat: -1, MaxLat: 1, MinLng: 179.5, MaxLng: -178.5}},
+		{name: "on antimeridian", p: Point{Lng: 180}, radiusKm: kmPerDegree, want: Box{MinLat: -1, MaxLat: 1, MinLng: 179, MaxLng: -179}},
+		{name: "on antimeridian zero radius", p: Point{Lng: -180}, radiusKm: 0, want: Box{MinLat: 0, MaxLat: 0, MinLng: -180, MaxLng: -180}},
+
+		// every longitude is in range near a pole
+		{name: "near north pole", p: Point{Lat: 89.5, Lng: 45}, radiusKm: kmPerDegree, want: Box{MinLat: 88.5, MaxLat: 90, MinLng: -180, MaxLng: 180}},
+		{name: "near south pole", p: Point{Lat: -89.5, Lng: -45}, radiusKm: kmPerDegree, want: Box{MinLat: -90, MaxLat: -88.5, MinLng: -180, MaxLng: 180}},
+		{name: "on north pole", p: Point{Lat: 90}, radiusKm: 0, want: Box{MinLat: 90, MaxLat: 90, MinLng: -180, MaxLng: 180}},
+		{name: "radius past the pole", p: Point{Lat: 10}, radiusKm: 100 * kmPerDegree, want: Box{MinLat: -90, MaxLat: 90, MinLng: -180, MaxLng: 180}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BoundingBox(tt.p, tt.radiusKm)
+			for _, v := range []struct {
+				field     string
+				got, want float64
+			}{
+				{"MinLat", got.MinLat, tt.want.MinLat},
+				{"MaxLat", got.MaxLat, tt.want.MaxLat},
+				{"MinLng", got.MinLng, tt.want.MinLng},
+				{"MaxLng", got.MaxLng, tt.want.MaxLng},
+			} {
+				if math.IsNaN(v.got) || math.Abs(v.got-v.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", v.field, v.got, v.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPointValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Point
+		ok   bool
+	}{
+		{name: "origin", p: Point{}, ok: true},
+		{name: "corners", p: Point{Lat: 90, Lng: -180}, ok: true},
+		{name: "other corners", p: Point{Lat: -90, Lng: 180}, ok: true},
+		{name: "lat too high", p: Point{Lat: 90.0001}},
+		{name: "lat too low", p: Point{Lat: -90.0001}},
+		{name: "lng too high", p: Point{Lng: 180.0001}},
+		{name: "lng too low", p: Point{Lng: -180.0001}},
+		{name: "nan", p: Point{Lat: math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
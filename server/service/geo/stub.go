@@ -0,0 +1,48 @@
+package geo
+
+import (
+	"context"
+	"strings"
+)
+
+// Stub geocodes offline from a fixed list of city centres, good enough
+// for local development & "near me" at city level
+type Stub struct {
+	cities map[string]Point
+}
+
+func NewStub() *Stub {
+	return &Stub{cities: map[string]Point{
+		"mumbai":        {Lat: 19.0760, Lng: 72.8777},
+		"delhi":         {Lat: 28.6139, Lng: 77.2090},
+		"new delhi":     {Lat: 28.6139, Lng: 77.2090},
+		"bengaluru":     {Lat: 12.9716, Lng: 77.5946},
+		"bangalore":     {Lat: 12.9716, Lng: 77.5946},
+		"hyderabad":     {Lat: 17.3850, Lng: 78.4867},
+		"chennai":       {Lat: 13.0827, Lng: 80.2707},
+		"kolkata":       {Lat: 22.5726, Lng: 88.3639},
+		"pune":          {Lat: 18.5204, Lng: 73.8567},
+		"ahmedabad":     {Lat: 23.0225, Lng: 72.5714},
+		"jaipur":        {Lat: 26.9124, Lng: 75.7873},
+		"lucknow":       {Lat: 26.8467, Lng: 80.9462},
+		"chandigarh":    {Lat: 30.7333, Lng: 76.7794},
+		"goa":           {Lat: 15.2993, Lng: 74.1240},
+		"kochi":         {Lat: 9.9312, Lng: 76.2673},
+		"indore":        {Lat: 22.7196, Lng: 75.8577},
+		"gurugram":      {Lat: 28.4595, Lng: 77.0266},
+		"noida":         {Lat: 28.5355, Lng: 77.3910},
+		"london":        {Lat: 51.5074, Lng: -0.1278},
+		"new york":      {Lat: 40.7128, Lng: -74.0060},
+		"san francisco": {Lat: 37.7749, Lng: -122.4194},
+		"singapore":     {Lat: 1.3521, Lng: 103.8198},
+		"dubai":         {Lat: 25.2048, Lng: 55.2708},
+	}}
+}
+
+func (s *Stub) Geocode(ctx context.Context, addr Address) (*Point, error) {
+	p, ok := s.cities[strings.ToLower(strings.TrimSpace(addr.City))]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
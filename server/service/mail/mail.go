@@ -1,100 +1,263 @@
 package mail
 
 import (
+	"context"
 	"fmt"
-	"net/smtp"
-	"os"
-	"strings"
 
 	"github.com/yeshu2004/go-event-booking/models"
 )
 
-func SendMail(data models.PDFContent, fileLink string) error {
-	smtpHost := "smtp.gmail.com"
-	smtpPort := 587
-	smtpUser := os.Getenv("ADMIN_MAIL")
-	smtpPass := os.Getenv("ADMIN_PASSWORD")
+const dateLayout = "02 Jan 2006, 03:04 PM"
 
-	if smtpUser == "" || smtpPass == "" {
-		return fmt.Errorf("smtp credentials missing")
+func SendMail(ctx context.Context, m Mailer, data models.PDFContent, fileLink string) error {
+	view := struct {
+		footer
+		Updated   bool
+		UserName  string
+		Intro     string
+		EventName string
+		EventDate string
+		BookingID int
+		Ticket    string
+		Seats     int
+		Amount    string
+		Link      string
+	}{
+		UserName:  data.UserName,
+		Intro:     "Your ticket has been successfully booked!",
+		EventName: data.EventName,
+		EventDate: data.EventDateTime.Format(dateLayout),
+		BookingID: data.BookingID,
+		Ticket:    ticketName(data.TierName),
+		Seats:     data.SeatsBooked,
+		Amount:    models.FormatAmount(data.Amount, data.Currency),
+		Link:      fileLink,
+	}
+	if data.SeatsCancelled > 0 {
+		view.Updated = true
+		view.Intro = fmt.Sprintf("%d seat(s) of your booking were cancelled, here is your updated ticket.", data.SeatsCancelled)
 	}
 
-	to := []string{data.UserEmail}
-	subject := fmt.Sprintf(`Your Ticket Is Confirmed | Booking %d`, data.BookingID)
-	body := fmt.Sprintf(`
-Hello %s,
-
-Your ticket has been successfully booked! 
+	msg, err := render("booking", view)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{data.UserEmail}
+	return m.Send(ctx, msg)
+}
 
-Event: %s
-Date & Time: %s
-Booking ID: %d
-Seats: %d
+func SendEditEventMail(ctx context.Context, m Mailer, toEmail string, data models.EventEditedPayload) error {
+	type change struct {
+		Title, Old, New string
+	}
+	view := struct {
+		footer
+		EventName string
+		Changes   []change
+	}{
+		EventName: fmt.Sprintf("Event #%d", data.EventID),
+	}
+	for _, c := range data.Changes {
+		view.Changes = append(view.Changes, change{Title: formatChangeType(c.Type), Old: c.Old, New: c.New})
+		if c.Type == models.EventNameChanged {
+			view.EventName = c.New
+		}
+	}
 
-You can download your ticket receipt using the link below:
-%s
+	msg, err := render("edit_event", view)
+	if err != nil {
+		return err
+	}
+	// one mail per attendee, they don't see each other's address
+	msg.To = []string{toEmail}
+	return m.Send(ctx, msg)
+}
 
-For security reasons, this link expires in 48 hours.
-If the download link has expired, you can log in to your account and download your ticket anytime.
-		
-Please keep this receipt with you during the event.
-If you have any questions, feel free to reply to this email.
+func SendWaitlistOfferMail(ctx context.Context, m Mailer, data models.WaitlistOfferPayload) error {
+	view := struct {
+		footer
+		UserName  string
+		EventName string
+		EventDate string
+		Seats     int64
+		ExpiresAt string
+		HoldID    string
+	}{
+		UserName:  data.UserName,
+		EventName: data.EventName,
+		EventDate: data.EventDate.Format(dateLayout),
+		Seats:     data.Seats,
+		ExpiresAt: data.ExpiresAt.Format(dateLayout),
+		HoldID:    data.HoldID,
+	}
 
-Best regards,
-Ticket One Team
-`,
-		data.UserName,
-		data.EventName,
-		data.EventDateTime.Format("02 Jan 2006, 03:04 PM"),
-		data.BookingID,
-		data.SeatsBooked,
-		fileLink,
-	)
+	msg, err := render("waitlist_offer", view)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{data.UserEmail}
+	return m.Send(ctx, msg)
+}
 
-	m := fmt.Sprintf("To: %v\r\n"+"Subject: %v\r\n"+"\r\n"+"%v\r\n", to, subject, body)
+func SendRefundMail(ctx context.Context, m Mailer, data models.RefundPayload, refund models.Refund) error {
+	reference := "-"
+	if refund.GatewayRefundID != nil {
+		reference = *refund.GatewayRefundID
+	}
+	view := struct {
+		footer
+		UserName  string
+		EventName string
+		BookingID int64
+		Amount    string
+		Percent   int
+		Reference string
+	}{
+		UserName:  data.UserName,
+		EventName: data.EventName,
+		BookingID: data.BookingID,
+		Amount:    models.FormatAmount(refund.Amount, refund.Currency),
+		Percent:   refund.Percent,
+		Reference: reference,
+	}
 
-	msg := []byte(m)
+	msg, err := render("refund", view)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{data.UserEmail}
+	return m.Send(ctx, msg)
+}
 
-	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+func SendReminderMail(ctx context.Context, m Mailer, data models.ReminderPayload) error {
+	when := fmt.Sprintf("in %d hours", data.HoursBefore)
+	if data.HoursBefore == 24 {
+		when = "tomorrow"
+	}
+	view := struct {
+		footer
+		UserName  string
+		EventName string
+		EventDate string
+		Location  string
+		BookingID int64
+		Seats     int64
+		When      string
+	}{
+		UserName:  data.UserName,
+		EventName: data.EventName,
+		EventDate: data.EventDate.Format(dateLayout),
+		Location:  data.Location,
+		BookingID: data.BookingID,
+		Seats:     data.Seats,
+		When:      when,
+	}
 
-	return smtp.SendMail(fmt.Sprintf("%s:%d", smtpHost, smtpPort), auth, smtpUser, to, msg)
+	msg, err := render("reminder", view)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{data.UserEmail}
+	return m.Send(ctx, msg)
 }
 
-func SendEditEventMail(toEmail string, data models.EventEditedPayload) error {
-	smtpHost := "smtp.gmail.com"
-	smtpPort := 587
-	smtpUser := os.Getenv("ADMIN_MAIL")
-	smtpPass := os.Getenv("ADMIN_PASSWORD")
+func SendEventCancelledMail(ctx context.Context, m Mailer, data models.EventCancelledPayload) error {
+	view := struct {
+		footer
+		UserName  string
+		EventName string
+		EventDate string
+		BookingID int64
+		Seats     int64
+		Reason    string
+		Refund    string
+	}{
+		UserName:  data.UserName,
+		EventName: data.EventName,
+		EventDate: data.EventDate.Format(dateLayout),
+		BookingID: data.BookingID,
+		Seats:     data.Seats,
+		Reason:    data.Reason,
+	}
+	if data.RefundAmount > 0 {
+		view.Refund = models.FormatAmount(data.RefundAmount, data.Currency)
+	}
 
-	if smtpUser == "" || smtpPass == "" {
-		return fmt.Errorf("smtp credentials missing")
+	msg, err := render("event_cancelled", view)
+	if err != nil {
+		return err
 	}
+	msg.To = []string{data.UserEmail}
+	return m.Send(ctx, msg)
+}
 
+func SendNewEventMail(ctx context.Context, m Mailer, data models.NewEventPayload) error {
 	to := data.To
-	subject := fmt.Sprintf("Important Update for Event #%d", data.EventID)
-	var body strings.Builder
-	body.WriteString("Hello,\n\n")
-	body.WriteString("There have been important updates to an event you booked:\n\n")
-
-	for _, change := range data.Changes {
-		body.WriteString(fmt.Sprintf(
-			"- %s\n  From: %s\n  To:   %s\n\n",
-			formatChangeType(change.Type),
-			change.Old,
-			change.New,
-		))
+	view := struct {
+		footer
+		UserName  string
+		OrgName   string
+		EventName string
+		EventDate string
+		City      string
+		EventID   int64
+	}{
+		UserName:  to.Name,
+		OrgName:   data.OrgName,
+		EventName: data.EventName,
+		EventDate: data.EventDate.Format(dateLayout),
+		City:      data.City,
+		EventID:   data.EventID,
 	}
 
-	body.WriteString("Your original ticket PDF remains valid.\n")
-	body.WriteString("Please make note of these changes before attending.\n\n")
-	body.WriteString("— Team TicketOne\n")
+	msg, err := render("new_event", view)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{to.Email}
+	return m.Send(ctx, msg)
+}
 
-	m := fmt.Sprintf("To: %v\r\n"+"Subject: %v\r\n"+"\r\n"+"%v\r\n", to, subject, body.String())
+func SendDigestMail(ctx context.Context, m Mailer, data models.DigestPayload) error {
+	type event struct {
+		Name, Date, City, OrganizedBy string
+	}
+	events := func(list []models.DigestEvent) []event {
+		out := make([]event, 0, len(list))
+		for _, e := range list {
+			out = append(out, event{Name: e.EventName, Date: e.EventDate.Format(dateLayout), City: e.City, OrganizedBy: e.OrganizedBy})
+		}
+		return out
+	}
+	view := struct {
+		footer
+		UserName  string
+		City      string
+		Following []event
+		InCity    []event
+	}{
+		footer:    footer{UnsubscribeURL: data.UnsubscribeURL},
+		UserName:  data.UserName,
+		City:      data.City,
+		Following: events(data.Following),
+		InCity:    events(data.InCity),
+	}
 
-	msg := []byte(m);
-	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+	msg, err := render("digest", view)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{data.UserEmail}
+	msg.ListUnsubscribe = data.UnsubscribeURL
+	return m.Send(ctx, msg)
+}
 
-	return smtp.SendMail(fmt.Sprintf("%s:%d", smtpHost, smtpPort), auth, smtpUser, []string{toEmail}, msg)
+// events without tiers have a single general ticket
+func ticketName(tier string) string {
+	if tier == "" {
+		return "General"
+	}
+	return tier
 }
 
 func formatChangeType(t models.EventChangeType) string {
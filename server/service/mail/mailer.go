@@ -0,0 +1,336 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is one email, Text is required and HTML is sent along as the
+// alternative part when set
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+
+	FromName        string // overrides the configured sender name
+	ReplyTo         string
+	ListUnsubscribe string // url, only set on mails users can opt out of
+	Headers         map[string]string
+}
+
+// Mailer sends emails, implemented by SMTP and by a file sink for local
+// development
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Sender is who the mails come from
+type Sender struct {
+	Name    string
+	Address string
+	ReplyTo string
+}
+
+// NewMailerFromEnv returns the mailer picked by MAILER, defaults to smtp
+func NewMailerFromEnv() (Mailer, error) {
+	from := Sender{
+		Name:    os.Getenv("MAIL_FROM_NAME"),
+		Address: os.Getenv("MAIL_FROM"),
+		ReplyTo: os.Getenv("MAIL_REPLY_TO"),
+	}
+	if from.Name == "" {
+		from.Name = "Ticket One Team"
+	}
+
+	switch name := os.Getenv("MAILER"); name {
+	case "", "smtp":
+		cfg := SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     587,
+			TLS:      os.Getenv("SMTP_TLS"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+		if cfg.Host == "" {
+			cfg.Host = "smtp.gmail.com"
+		}
+		if p := os.Getenv("SMTP_PORT"); p != "" {
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+			}
+			cfg.Port = port
+		}
+		if cfg.TLS == "" {
+			cfg.TLS = TLSStartTLS
+		}
+		// older setups only have the gmail admin credentials
+		if cfg.Username == "" && cfg.Password == "" {
+			cfg.Username = os.Getenv("ADMIN_MAIL")
+			cfg.Password = os.Getenv("ADMIN_PASSWORD")
+		}
+		if from.Address == "" {
+			from.Address = cfg.Username
+		}
+		return NewSMTPMailer(cfg, from)
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mailbox"
+		}
+		if from.Address == "" {
+			from.Address = "no-reply@localhost"
+		}
+		return NewFileMailer(dir, from)
+	default:
+		return nil, fmt.Errorf("unknown mailer: %q", name)
+	}
+}
+
+const (
+	TLSStartTLS = "starttls" // plain connection upgraded, port 587
+	TLSImplicit = "tls"      // tls from the start, port 465
+	TLSNone     = "none"     // local test servers only
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	TLS      string
+	Username string
+	Password string
+}
+
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	from Sender
+}
+
+func NewSMTPMailer(cfg SMTPConfig, from Sender) (*SMTPMailer, error) {
+	switch cfg.TLS {
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("invalid SMTP_TLS: %q", cfg.TLS)
+	}
+	if from.Address == "" {
+		return nil, fmt.Errorf("smtp credentials missing")
+	}
+	return &SMTPMailer{cfg: cfg, from: from}, nil
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := build(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsCfg := &tls.Config{ServerName: s.cfg.Host}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	if s.cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsCfg}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial error: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s doesn't support STARTTLS", s.cfg.Host)
+		}
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// FileMailer writes every mail as an .eml file to dir instead of sending
+// it, they open in any mail client
+type FileMailer struct {
+	dir  string
+	from Sender
+}
+
+func NewFileMailer(dir string, from Sender) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (f *FileMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := build(f.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), randomHex(4))
+	p := filepath.Join(f.dir, name)
+	if err := os.WriteFile(p, raw, 0o644); err != nil {
+		return err
+	}
+	log.Printf("mail %q to %s written to %s", msg.Subject, strings.Join(msg.To, ", "), p)
+	return nil
+}
+
+// build renders msg as a MIME message, multipart/alternative when it has
+// an html body
+func build(from Sender, msg Message) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, fmt.Errorf("mail has no recipients")
+	}
+
+	name := from.Name
+	if msg.FromName != "" {
+		name = msg.FromName
+	}
+	replyTo := from.ReplyTo
+	if msg.ReplyTo != "" {
+		replyTo = msg.ReplyTo
+	}
+
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+
+	header("From", (&netmail.Address{Name: name, Address: from.Address}).String())
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+	if replyTo != "" {
+		header("Reply-To", replyTo)
+	}
+	if msg.ListUnsubscribe != "" {
+		header("List-Unsubscribe", "<"+msg.ListUnsubscribe+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	keys := make([]string, 0, len(msg.Headers))
+	for k := range msg.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header(textproto.CanonicalMIMEHeaderKey(k), msg.Headers[k])
+	}
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQP(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	// least preferred first, clients show the last part they understand
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQP(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(fromAddress string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(fromAddress, "@"); i >= 0 && i < len(fromAddress)-1 {
+		domain = fromAddress[i+1:]
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// every mail <name> has <name>.txt defining "<name>.subject" and
+// "<name>.text", and <name>.html defining "<name>.html" on layout.html
+//
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("mail").Funcs(htmltemplate.FuncMap{
+		"row": func(label string, value interface{}) tableRow {
+			return tableRow{Label: label, Value: value}
+		},
+	}).ParseFS(templateFS, "templates/*.html"))
+)
+
+type tableRow struct {
+	Label string
+	Value interface{}
+}
+
+// footer is embedded in the data of every template
+type footer struct {
+	UnsubscribeURL string
+}
+
+// render builds subject, text & html of the mail name from data
+func render(name string, data interface{}) (Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".text", data); err != nil {
+		return Message{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("render %s html: %w", name, err)
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimLeft(text.String(), "\n"),
+		HTML:    html.String(),
+	}, nil
+}
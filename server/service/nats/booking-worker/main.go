@@ -3,12 +3,43 @@ package main
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 
+	"github.com/yeshu2004/go-event-booking/service/blob"
+	"github.com/yeshu2004/go-event-booking/service/mail"
 	"github.com/yeshu2004/go-event-booking/service/nats"
 )
 
+// messages processed in parallel unless BOOKING_WORKER_CONCURRENCY is set
+const defaultConcurrency = 4
+
 func main() {
-	ctx := context.Background()
+	// SIGTERM stops fetching, in-flight messages are finished first
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	concurrency := defaultConcurrency
+	if v := os.Getenv("BOOKING_WORKER_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid BOOKING_WORKER_CONCURRENCY: %q", v)
+		}
+		concurrency = n
+	}
+
+	// ticket pdfs go to s3, or to disk with BLOB_STORE=local
+	store, err := blob.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	natsIns, err := nats.NewNATSIns()
 	if err != nil {
@@ -19,14 +50,16 @@ func main() {
 	if err := natsIns.CreateBookingStream(ctx); err != nil {
 		log.Fatal("stream creation failed:", err)
 	}
+	if err := natsIns.CreateDeadLetterStream(ctx); err != nil {
+		log.Fatal("stream creation failed:", err)
+	}
 
-	if err := natsIns.CreateBookingConsumer(ctx); err != nil {
+	if err := natsIns.CreateBookingConsumer(ctx, concurrency); err != nil {
 		log.Fatal("consumer creation failed:", err)
 	}
 
-
-	log.Println("Booking worker started")
-	if err := natsIns.ConsumeBookingEvent(ctx); err != nil {
+	log.Printf("Booking worker started with %d workers", concurrency)
+	if err := natsIns.ConsumeBookingEvent(ctx, concurrency, store, mailer); err != nil {
 		log.Fatal(err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,189 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/yeshu2004/go-event-booking/models"
+	"github.com/yeshu2004/go-event-booking/service/blob"
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/mail"
+)
+
+var _ bus.Bus = (*NATSIns)(nil)
+
+// Subscribe consumes subject through the durable consumer named durable,
+// failed messages are retried with backoff and dead-lettered after
+// maxDeliver like the other consumers
+func (n *NATSIns) Subscribe(ctx context.Context, subject, durable string, h bus.Handler) error {
+	stream, err := streamOf(subject)
+	if err != nil {
+		return err
+	}
+
+	c, err := n.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Name:          durable,
+		Durable:       durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       2 * time.Minute,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		FilterSubject: subject,
+		MaxDeliver:    -1, // dead-lettered by us after maxDeliver
+	})
+	if err != nil {
+		return fmt.Errorf("consumer %s creation error: %w", durable, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("shutting down %s consumer...", durable)
+			return nil
+		default:
+			msgs, err := c.Fetch(1, jetstream.FetchMaxWait(5*time.Second))
+			if err != nil {
+				if err == jetstream.ErrNoMessages {
+					continue
+				}
+				log.Println("fetch error:", err)
+				continue
+			}
+
+			for msg := range msgs.Messages() {
+				m := bus.Message{
+					Subject: msg.Subject(),
+					ID:      msg.Headers().Get(jetstream.MsgIDHeader),
+					Data:    msg.Data(),
+					Attempt: 1,
+				}
+				if meta, err := msg.Metadata(); err == nil {
+					m.Attempt = meta.NumDelivered
+				}
+
+				if err := h(ctx, m); err != nil {
+					log.Printf("error in processing %s message: %v", m.Subject, err)
+					n.retryOrDeadLetter(ctx, msg, err)
+					continue
+				}
+
+				// acknowledges i.e message consumed
+				if err := msg.Ack(); err != nil {
+					log.Println("ack failed:", err)
+				}
+			}
+		}
+	}
+}
+
+// streamOf returns the stream a subject is stored in
+func streamOf(subject string) (string, error) {
+	switch {
+	case strings.HasPrefix(subject, "BOOKING."):
+		return "BOOKINGS", nil
+	case strings.HasPrefix(subject, "EVENT."):
+		return "EVENT", nil
+	case strings.HasPrefix(subject, "ALLOC."):
+		return "ALLOCATIONS", nil
+	default:
+		return "", fmt.Errorf("no stream for subject %s", subject)
+	}
+}
+
+// BookingHandler generates the ticket pdf, uploads it & mails the link,
+// for subscribing to bus.SubjectBookingNew on any bus
+func BookingHandler(store blob.Store, mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		return processBookingMessage(ctx, msg.Data, store, mailer)
+	}
+}
+
+// EditEventHandler mails the attendees of an edited event
+func EditEventHandler(mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		return processEditEventMessage(ctx, msg.Data, mailer)
+	}
+}
+
+// WaitlistHandler mails seat offers to waitlisted users
+func WaitlistHandler(mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		return processWaitlistMessage(ctx, msg.Data, mailer)
+	}
+}
+
+// ReminderHandler mails reminders of upcoming events
+func ReminderHandler(mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		var payload models.ReminderPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			return fmt.Errorf("invalid reminder payload: %w", err)
+		}
+		if err := mail.SendReminderMail(ctx, mailer, payload); err != nil {
+			return err
+		}
+		log.Printf("reminder %d of booking %d sent to %s", payload.ReminderID, payload.BookingID, payload.UserEmail)
+		return nil
+	}
+}
+
+// EventCancelledHandler tells attendees their event was cancelled
+func EventCancelledHandler(mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		var payload models.EventCancelledPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			return fmt.Errorf("invalid event cancelled payload: %w", err)
+		}
+		if err := mail.SendEventCancelledMail(ctx, mailer, payload); err != nil {
+			return err
+		}
+		log.Printf("event(%d) cancellation mail sent to %s", payload.EventID, payload.UserEmail)
+		return nil
+	}
+}
+
+// NewEventHandler mails the followers of an organization about its new
+// public event
+func NewEventHandler(mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		var payload models.NewEventPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			return fmt.Errorf("invalid new event payload: %w", err)
+		}
+		if err := mail.SendNewEventMail(ctx, mailer, payload); err != nil {
+			return err
+		}
+		log.Printf("event(%d) of org %d announced to %s", payload.EventID, payload.OrgID, payload.To.Email)
+		return nil
+	}
+}
+
+// DigestHandler mails the weekly digest of a user
+func DigestHandler(mailer mail.Mailer) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		var payload models.DigestPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			return fmt.Errorf("invalid digest payload: %w", err)
+		}
+		if err := mail.SendDigestMail(ctx, mailer, payload); err != nil {
+			return err
+		}
+		log.Printf("digest of week %s sent to %s", payload.Period, payload.UserEmail)
+		return nil
+	}
+}
+
+// RefundHandler hands refund jobs to process
+func RefundHandler(process func(context.Context, models.RefundPayload) error) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		var payload models.RefundPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			return fmt.Errorf("invalid refund payload: %w", err)
+		}
+		return process(ctx, payload)
+	}
+}
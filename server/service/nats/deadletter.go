@@ -0,0 +1,233 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/yeshu2004/go-event-booking/models"
+)
+
+const (
+	deadLetterStream = "DEADLETTER"
+	deadLetterPrefix = "DLQ."
+
+	// deliveries before a message is dead-lettered
+	maxDeliver = 5
+
+	retryBaseDelay = 5 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+)
+
+// headers kept on a dead-lettered message
+const (
+	hdrSubject   = "Dlq-Subject"
+	hdrMsgID     = "Dlq-Msg-Id"
+	hdrStream    = "Dlq-Stream"
+	hdrStreamSeq = "Dlq-Stream-Seq"
+	hdrConsumer  = "Dlq-Consumer"
+	hdrReason    = "Dlq-Reason"
+	hdrAttempts  = "Dlq-Attempts"
+	hdrFailedAt  = "Dlq-Failed-At"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// CreateDeadLetterStream keeps messages whose retries ran out, unlike the
+// work queues it is only cleaned up by replay/discard (or after 30 days)
+func (n *NATSIns) CreateDeadLetterStream(ctx context.Context) error {
+	_, err := n.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:        deadLetterStream,
+		Description: "Messages that exhausted their deliveries",
+		Subjects:    []string{deadLetterPrefix + ">"},
+		Storage:     jetstream.FileStorage, // on disk storage
+		Retention:   jetstream.LimitsPolicy,
+		MaxAge:      30 * 24 * time.Hour,
+		MaxMsgs:     -1, // unlimit
+		MaxBytes:    -1,
+	})
+
+	if err != nil && err != jetstream.ErrStreamNameAlreadyInUse {
+		return fmt.Errorf("creating dead-letter stream error: %w", err)
+	}
+
+	return nil
+}
+
+// retryDelay doubles from retryBaseDelay on every delivery
+func retryDelay(delivered uint64) time.Duration {
+	d := retryBaseDelay
+	for i := uint64(1); i < delivered && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return d
+}
+
+// retryOrDeadLetter handles a message that failed processing, it is
+// nak'ed with backoff until maxDeliver and dead-lettered after that.
+// It reports whether msg was dead-lettered.
+func (n *NATSIns) retryOrDeadLetter(ctx context.Context, msg jetstream.Msg, procErr error) bool {
+	meta, err := msg.Metadata()
+	if err != nil {
+		log.Printf("message metadata error: %v", err)
+		_ = msg.NakWithDelay(retryBaseDelay)
+		return false
+	}
+
+	if meta.NumDelivered < maxDeliver {
+		_ = msg.NakWithDelay(retryDelay(meta.NumDelivered)) // negative acknowledges i.e message not consumed
+		return false
+	}
+
+	return n.deadLetter(ctx, msg, procErr.Error())
+}
+
+// deadLetter moves msg to the dead-letter stream, used directly for
+// messages retrying won't fix (e.g broken payloads). It reports whether
+// msg was dead-lettered, it stays in the work queue otherwise.
+func (n *NATSIns) deadLetter(ctx context.Context, msg jetstream.Msg, reason string) bool {
+	meta, err := msg.Metadata()
+	if err != nil {
+		log.Printf("message metadata error: %v", err)
+		_ = msg.NakWithDelay(retryBaseDelay)
+		return false
+	}
+
+	dlq := nats.NewMsg(deadLetterPrefix + msg.Subject())
+	dlq.Data = msg.Data()
+	dlq.Header.Set(hdrSubject, msg.Subject())
+	dlq.Header.Set(hdrMsgID, msg.Headers().Get(jetstream.MsgIDHeader))
+	dlq.Header.Set(hdrStream, meta.Stream)
+	dlq.Header.Set(hdrStreamSeq, strconv.FormatUint(meta.Sequence.Stream, 10))
+	dlq.Header.Set(hdrConsumer, meta.Consumer)
+	dlq.Header.Set(hdrReason, reason)
+	dlq.Header.Set(hdrAttempts, strconv.FormatUint(meta.NumDelivered, 10))
+	dlq.Header.Set(hdrFailedAt, time.Now().UTC().Format(time.RFC3339))
+
+	// a message is dead-lettered once even if the term below is lost
+	dlqID := fmt.Sprintf("dlq-%s-%d", meta.Stream, meta.Sequence.Stream)
+	if _, err := n.js.PublishMsg(ctx, dlq, jetstream.WithMsgID(dlqID)); err != nil {
+		// keep it in the work queue rather than lose it
+		log.Printf("failed to dead-letter %s message %d: %v", msg.Subject(), meta.Sequence.Stream, err)
+		_ = msg.NakWithDelay(retryMaxDelay)
+		return false
+	}
+
+	log.Printf("dead-lettered %s message %d after %d attempts: %s", msg.Subject(), meta.Sequence.Stream, meta.NumDelivered, reason)
+	if err := msg.Term(); err != nil {
+		log.Println("term failed:", err)
+	}
+	return true
+}
+
+// ListDeadLetters returns up to limit dead-lettered messages, oldest first
+func (n *NATSIns) ListDeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error) {
+	s, err := n.js.Stream(ctx, deadLetterStream)
+	if err != nil {
+		return nil, fmt.Errorf("get dead-letter stream error: %w", err)
+	}
+	info, err := s.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]models.DeadLetter, 0)
+	if info.State.Msgs == 0 {
+		return letters, nil
+	}
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq && len(letters) < limit; seq++ {
+		raw, err := s.GetMsg(ctx, seq)
+		if err != nil {
+			// discarded or replayed
+			if errors.Is(err, jetstream.ErrMsgNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		letters = append(letters, toDeadLetter(raw))
+	}
+	return letters, nil
+}
+
+// GetDeadLetter returns the dead-lettered message at seq
+func (n *NATSIns) GetDeadLetter(ctx context.Context, seq uint64) (*models.DeadLetter, error) {
+	s, err := n.js.Stream(ctx, deadLetterStream)
+	if err != nil {
+		return nil, fmt.Errorf("get dead-letter stream error: %w", err)
+	}
+	raw, err := s.GetMsg(ctx, seq)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+	letter := toDeadLetter(raw)
+	return &letter, nil
+}
+
+// ReplayDeadLetter publishes the message at seq to its original subject
+// again, with fresh attempts, and removes it from the dead-letter stream
+func (n *NATSIns) ReplayDeadLetter(ctx context.Context, seq uint64) error {
+	letter, err := n.GetDeadLetter(ctx, seq)
+	if err != nil {
+		return err
+	}
+
+	// new msg id, the original one may still be in the dedup window
+	msgID := fmt.Sprintf("replay-%d", seq)
+	if letter.MsgID != "" {
+		msgID = fmt.Sprintf("%s-replay-%d", letter.MsgID, seq)
+	}
+	if _, err := n.js.Publish(ctx, letter.Subject, []byte(letter.Payload), jetstream.WithMsgID(msgID)); err != nil {
+		return fmt.Errorf("error in replaying dead letter(%d): %v", seq, err)
+	}
+
+	return n.DiscardDeadLetter(ctx, seq)
+}
+
+// DiscardDeadLetter drops the message at seq for good
+func (n *NATSIns) DiscardDeadLetter(ctx context.Context, seq uint64) error {
+	s, err := n.js.Stream(ctx, deadLetterStream)
+	if err != nil {
+		return fmt.Errorf("get dead-letter stream error: %w", err)
+	}
+	if err := s.DeleteMsg(ctx, seq); err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) || strings.Contains(err.Error(), "no message found") {
+			return ErrDeadLetterNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func toDeadLetter(raw *jetstream.RawStreamMsg) models.DeadLetter {
+	letter := models.DeadLetter{
+		Seq:      raw.Sequence,
+		Subject:  raw.Header.Get(hdrSubject),
+		MsgID:    raw.Header.Get(hdrMsgID),
+		Stream:   raw.Header.Get(hdrStream),
+		Consumer: raw.Header.Get(hdrConsumer),
+		Reason:   raw.Header.Get(hdrReason),
+		FailedAt: raw.Time,
+		Payload:  string(raw.Data),
+	}
+	if letter.Subject == "" {
+		letter.Subject = strings.TrimPrefix(raw.Subject, deadLetterPrefix)
+	}
+	letter.StreamSeq, _ = strconv.ParseUint(raw.Header.Get(hdrStreamSeq), 10, 64)
+	letter.Attempts, _ = strconv.ParseUint(raw.Header.Get(hdrAttempts), 10, 64)
+	if t, err := time.Parse(time.RFC3339, raw.Header.Get(hdrFailedAt)); err == nil {
+		letter.FailedAt = t
+	}
+	return letter
+}
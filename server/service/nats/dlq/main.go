@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yeshu2004/go-event-booking/service/nats"
+)
+
+const usage = `usage: dlq <command>
+
+commands:
+  list [limit]     list dead-lettered messages, oldest first (default 50)
+  inspect <seq>    show a dead-lettered message with its payload
+  replay <seq>     publish the message to its original subject again
+  discard <seq>    drop the message for good
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	natsIns, err := nats.NewNATSIns()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Safe to call (idempotent)
+	if err := natsIns.CreateDeadLetterStream(ctx); err != nil {
+		log.Fatal("stream creation failed:", err)
+	}
+
+	switch cmd := os.Args[1]; cmd {
+	case "list":
+		limit := 50
+		if len(os.Args) > 2 {
+			if limit, err = strconv.Atoi(os.Args[2]); err != nil || limit <= 0 {
+				log.Fatal("invalid limit: ", os.Args[2])
+			}
+		}
+
+		letters, err := natsIns.ListDeadLetters(ctx, limit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(letters) == 0 {
+			fmt.Println("no dead-lettered messages")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SEQ\tSUBJECT\tMSG ID\tATTEMPTS\tFAILED AT\tREASON")
+		for _, l := range letters {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", l.Seq, l.Subject, l.MsgID, l.Attempts, l.FailedAt.Format(time.RFC3339), l.Reason)
+		}
+		w.Flush()
+
+	case "inspect":
+		letter, err := natsIns.GetDeadLetter(ctx, seqArg())
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, _ := json.MarshalIndent(letter, "", "  ")
+		fmt.Println(string(out))
+
+	case "replay":
+		seq := seqArg()
+		if err := natsIns.ReplayDeadLetter(ctx, seq); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("dead letter %d replayed\n", seq)
+
+	case "discard":
+		seq := seqArg()
+		if err := natsIns.DiscardDeadLetter(ctx, seq); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("dead letter %d discarded\n", seq)
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// seqArg reads the dead-letter sequence of inspect/replay/discard
+func seqArg() uint64 {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	seq, err := strconv.ParseUint(os.Args[2], 10, 64)
+	if err != nil || seq == 0 {
+		log.Fatal("invalid sequence: ", os.Args[2])
+	}
+	return seq
+}
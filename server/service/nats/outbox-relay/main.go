@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yeshu2004/go-event-booking/service/nats"
+	"github.com/yeshu2004/go-event-booking/service/outbox"
+	"github.com/yeshu2004/go-event-booking/storage"
+)
+
+func main() {
+	ctx := context.Background()
+
+	db, err := storage.ConnectDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	natsIns, err := nats.NewNATSIns()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Safe to call (idempotent)
+	if err := natsIns.CreateBookingStream(ctx); err != nil {
+		log.Fatal("stream creation failed:", err)
+	}
+	if err := natsIns.CreateEventStream(ctx); err != nil {
+		log.Fatal("stream creation failed:", err)
+	}
+
+	relay := outbox.NewRelay(db, natsIns, 100)
+
+	log.Println("Outbox relay started")
+	if err := relay.Run(ctx, time.Second); err != nil {
+		log.Fatal(err)
+	}
+}
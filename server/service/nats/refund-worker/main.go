@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/mail"
+	"github.com/yeshu2004/go-event-booking/service/nats"
+	"github.com/yeshu2004/go-event-booking/service/payment"
+	"github.com/yeshu2004/go-event-booking/service/refund"
+	"github.com/yeshu2004/go-event-booking/storage"
+)
+
+func main() {
+	db, err := storage.ConnectDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	gateway, err := payment.NewGatewayFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	processor := refund.NewProcessor(db, gateway, mailer)
+	nats.RunWorker("refund-worker", bus.SubjectBookingRefund, nats.RefundHandler(processor.Process))
+}
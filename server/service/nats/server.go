@@ -6,24 +6,37 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
-	cloud "github.com/yeshu2004/go-event-booking/aws"
 	"github.com/yeshu2004/go-event-booking/models"
+	"github.com/yeshu2004/go-event-booking/service/blob"
+	"github.com/yeshu2004/go-event-booking/service/bus"
 	"github.com/yeshu2004/go-event-booking/service/mail"
 	pdf "github.com/yeshu2004/go-event-booking/service/pdf"
 )
 
+const (
+	// a booking message taking longer is given up on & redelivered
+	bookingProcessTimeout = 10 * time.Minute
+	// well within the 2 minute AckWait of the consumers
+	heartbeatInterval = 30 * time.Second
+)
+
 type NATSIns struct {
 	js jetstream.JetStream
 }
 
-// connecting to nats server -- running on docker
+// connecting to nats server -- running on docker, NATS_URL overrides
+// the default local url
 func NewNATSIns() (*NATSIns, error) {
-	nc, err := nats.Connect(nats.DefaultURL)
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	nc, err := nats.Connect(url)
 	if err != nil {
 		return nil, fmt.Errorf("nats connection error: %w", err)
 	}
@@ -57,7 +70,9 @@ func (n *NATSIns) CreateBookingStream(ctx context.Context) error {
 	return nil
 }
 
-func (n *NATSIns) CreateBookingConsumer(ctx context.Context) error {
+// CreateBookingConsumer lets up to concurrency messages be in flight, one
+// per worker of ConsumeBookingEvent
+func (n *NATSIns) CreateBookingConsumer(ctx context.Context, concurrency int) error {
 	_, err := n.js.CreateOrUpdateConsumer(ctx, "BOOKINGS", jetstream.ConsumerConfig{
 		Name:    "booking-worker",
 		Durable: "booking-worker",
@@ -68,10 +83,10 @@ func (n *NATSIns) CreateBookingConsumer(ctx context.Context) error {
 		DeliverPolicy: jetstream.DeliverAllPolicy,
 		ReplayPolicy:  jetstream.ReplayInstantPolicy,
 
-		MaxDeliver:    5, // retry 5 times
-		FilterSubject: "BOOKING.new",
+		MaxDeliver:    -1, // dead-lettered by us after maxDeliver
+		FilterSubject: bus.SubjectBookingNew,
 
-		MaxAckPending: 1,
+		MaxAckPending: concurrency,
 	})
 
 	if err != nil {
@@ -81,32 +96,55 @@ func (n *NATSIns) CreateBookingConsumer(ctx context.Context) error {
 	return nil
 }
 
-// PublishBooingEvent is used to publish booking event into nats stream
-func (n *NATSIns) PublishBookingEvent(ctx context.Context, bookingID int, payload []byte) error {
-	_, err := n.js.Publish(ctx, "BOOKING.new", payload, jetstream.WithMsgID(fmt.Sprintf("booking-%d", bookingID)))
+// Publish sends payload on subject, jetstream drops a msgID it has seen
+// within its dedup window
+func (n *NATSIns) Publish(ctx context.Context, subject, msgID string, payload []byte) error {
+	ack, err := n.js.Publish(ctx, subject, payload, jetstream.WithMsgID(msgID))
 	if err != nil {
-		return fmt.Errorf("error in publishing new booking event: %v", err)
+		return fmt.Errorf("error in publishing %s (%s): %v", subject, msgID, err)
+	}
+	if ack.Duplicate {
+		log.Printf("%s (%s) was already published", subject, msgID)
 	}
 	return nil
 }
 
-// ConsumeBookingEvent is used to consume events from nats stream defined
-func (n *NATSIns) ConsumeBookingEvent(ctx context.Context) error {
+// ConsumeBookingEvent is used to consume events from nats stream defined,
+// concurrency workers process messages in parallel. Once ctx is done no
+// new messages are fetched and in-flight ones are finished before return.
+func (n *NATSIns) ConsumeBookingEvent(ctx context.Context, concurrency int, store blob.Store, mailer mail.Mailer) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
 	c, err := n.js.Consumer(ctx, "BOOKINGS", "booking-worker")
 	if err != nil {
 		return fmt.Errorf("get consumer error: %w", err)
 	}
 
-	cfg := cloud.LoadAwsConifg()
-	awsClient := cloud.NewS3Service(cfg)
+	// bounded pool, the fetch loop blocks while every worker is busy
+	jobs := make(chan jetstream.Msg)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				n.handleBookingMessage(msg, store, mailer)
+			}
+		}()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("shutting down booking event consumer...")
+			log.Println("shutting down booking event consumer, finishing in-flight messages...")
+			close(jobs)
+			wg.Wait()
+			log.Println("booking event consumer stopped")
 			return nil
 		default:
-			msgs, err := c.Fetch(1, jetstream.FetchMaxWait(5*time.Second))
+			msgs, err := c.Fetch(concurrency, jetstream.FetchMaxWait(5*time.Second))
 			if err != nil {
 				if err == jetstream.ErrNoMessages {
 					continue
@@ -116,23 +154,63 @@ func (n *NATSIns) ConsumeBookingEvent(ctx context.Context) error {
 			}
 
 			for msg := range msgs.Messages() {
-				if err := processBookingMessage(ctx, msg.Data(), awsClient); err != nil {
-					log.Printf("error in processing message data: %v", err)
-					_ = msg.NakWithDelay(10 * time.Second) // negative acknowledges i.e message not consumed
-					continue                               // do not block
-				}
+				jobs <- msg
+			}
+		}
+	}
+}
 
-				// acknowledges i.e message consumed
-				if err := msg.Ack(); err != nil {
-					log.Println("ack failed:", err)
+// handleBookingMessage runs on a pool worker, it isn't tied to the
+// consumer ctx so a shutdown lets the message finish
+func (n *NATSIns) handleBookingMessage(msg jetstream.Msg, store blob.Store, mailer mail.Mailer) {
+	ctx, cancel := context.WithTimeout(context.Background(), bookingProcessTimeout)
+	defer cancel()
+
+	stop := keepInProgress(ctx, msg)
+	err := processBookingMessage(ctx, msg.Data(), store, mailer)
+	stop()
+
+	if err != nil {
+		log.Printf("error in processing message data: %v", err)
+		// ctx may be what timed out, dead-lettering gets its own
+		dctx, dcancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer dcancel()
+		n.retryOrDeadLetter(dctx, msg, err)
+		return
+	}
+
+	// acknowledges i.e message consumed
+	if err := msg.Ack(); err != nil {
+		log.Println("ack failed:", err)
+	}
+}
+
+// keepInProgress tells the server msg is still being worked on every
+// heartbeatInterval, so slow pdf/s3/smtp steps don't run into AckWait.
+// Heartbeats end with ctx, a stuck message is then redelivered.
+func keepInProgress(ctx context.Context, msg jetstream.Msg) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					log.Println("in-progress failed:", err)
 				}
 			}
 		}
-	}
+	}()
+	return func() { close(done) }
 }
 
 // helper function to process the msg data from consumers
-func processBookingMessage(ctx context.Context, msg []byte, awsClient *cloud.S3Service) error {
+func processBookingMessage(ctx context.Context, msg []byte, store blob.Store, mailer mail.Mailer) error {
 	log.Printf("Processing booking message: %s", string(msg))
 
 	var data models.PDFContent
@@ -152,13 +230,12 @@ func processBookingMessage(ctx context.Context, msg []byte, awsClient *cloud.S3S
 		return err
 	}
 
-	// upload it over cloud
-	bucketName := "ticket-one"
-	keyName := fmt.Sprintf("receipt/user-%d/booking_%d.pdf", data.UserID, data.BookingID)
-	if err := awsClient.UploadObject(ctx, bucketName, keyName, file, aws.String("application/pdf")); err != nil {
+	// upload it to the blob store (s3 or local)
+	keyName := blob.ReceiptKey(data.UserID, data.BookingID)
+	if err := store.Put(ctx, keyName, file, "application/pdf"); err != nil {
 		return err
 	}
-	log.Printf("PDF uploaded to S3 for booking ID %d", data.BookingID)
+	log.Printf("PDF uploaded for booking ID %d", data.BookingID)
 
 	// async cleanup -- non blocking
 	go func() {
@@ -168,14 +245,14 @@ func processBookingMessage(ctx context.Context, msg []byte, awsClient *cloud.S3S
 	}()
 
 	// get url of the pdf file 
-	link, err := awsClient.GetPresignDownloadURL(ctx, bucketName, keyName, 60*24*2) // 2 days
+	link, err := store.DownloadURL(ctx, keyName, 48*time.Hour) // 2 days
 	if err != nil {
 		return err
 	}
 	log.Printf("presigned URL generated for booking ID %d", data.BookingID)
 	
 	// send mail to user with the link
-	if err := mail.SendMail(data, link); err != nil {
+	if err := mail.SendMail(ctx, mailer, data, link); err != nil {
 		return err
 	}
 	log.Printf("confirmation email sent to %s for booking ID %d", data.UserEmail, data.BookingID)
@@ -202,51 +279,100 @@ func (n *NATSIns) CreateEventStream(ctx context.Context) error {
 	return nil
 }
 
-// PublishEditEvent is used to publish editEvent event into nats stream server 
-func (n *NATSIns) PublishEditEvent(ctx context.Context, eventID int, payload []byte) error{
-	ack, err := n.js.Publish(ctx, "EVENT.edit", payload, jetstream.WithMsgID(fmt.Sprintf("edit-event-%d-%d", eventID, time.Now().UnixNano()),
-	));
-	if err != nil {
-		return fmt.Errorf("error in publishing new edit-event(%d) event: %v",eventID, err)
+func processEditEventMessage(ctx context.Context, msg []byte, mailer mail.Mailer) error{
+	var payload models.EventEditedPayload;
+	if err := json.Unmarshal(msg, &payload); err != nil {
+		log.Printf("invalid event edit payload: %v", err)
+	}
+
+	for _, email := range payload.To{
+		if err := mail.SendEditEventMail(ctx, mailer, email, payload); err != nil{
+			return err
+		}
+		fmt.Printf("event(%d) update mail send to %s", payload.EventID, email)
+	}
+	return nil;
+}
+
+func processWaitlistMessage(ctx context.Context, msg []byte, mailer mail.Mailer) error {
+	var payload models.WaitlistOfferPayload
+	if err := json.Unmarshal(msg, &payload); err != nil {
+		return fmt.Errorf("invalid waitlist offer payload: %w", err)
 	}
-	log.Printf("published EVENT.edit to stream=%s seq=%d", ack.Stream, ack.Sequence)
+
+	// offer already gone, nothing to tell the user
+	if time.Now().After(payload.ExpiresAt) {
+		log.Printf("waitlist offer %d expired before mail was sent", payload.WaitlistID)
+		return nil
+	}
+
+	if err := mail.SendWaitlistOfferMail(ctx, mailer, payload); err != nil {
+		return err
+	}
+	log.Printf("waitlist offer mail sent to %s for event %d", payload.UserEmail, payload.EventID)
 	return nil
 }
 
-func (n *NATSIns) CreateEditEventConsumer(ctx context.Context) error{
-	_, err := n.js.CreateOrUpdateConsumer(ctx, "EVENT", jetstream.ConsumerConfig{
-		Name:           "edit-event-worker",
-		Durable:        "edit-event-worker",
-		AckPolicy:      jetstream.AckExplicitPolicy,
-		AckWait:        2 * time.Minute,
-		DeliverPolicy:  jetstream.DeliverAllPolicy,
-		FilterSubject:  "EVENT.edit",
-		MaxDeliver:     5,
+// CreateAllocationStream holds booking requests of hot events until the
+// booking allocator turns them into bookings
+func (n *NATSIns) CreateAllocationStream(ctx context.Context) error {
+	_, err := n.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:        "ALLOCATIONS",
+		Description: "Queued booking requests",
+		Subjects:    []string{"ALLOC.*"},
+		Storage:     jetstream.FileStorage, // on disk storage
+		Retention:   jetstream.WorkQueuePolicy,
+		Discard:     jetstream.DiscardOld,
+		MaxMsgs:     -1, // unlimit
+		MaxBytes:    -1,
 	})
 
+	if err != nil && err != jetstream.ErrStreamNameAlreadyInUse {
+		return fmt.Errorf("creating allocation stream error: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAllocationConsumer creates the consumer of the allocator, at most
+// one batch is in flight so requests are allocated in arrival order
+func (n *NATSIns) CreateAllocationConsumer(ctx context.Context, batchSize int) error {
+	_, err := n.js.CreateOrUpdateConsumer(ctx, "ALLOCATIONS", jetstream.ConsumerConfig{
+		Name:          "booking-allocator",
+		Durable:       "booking-allocator",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       time.Minute,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		FilterSubject: bus.SubjectBookingRequest,
+		MaxDeliver:    -1, // dead-lettered by us after maxDeliver
+		MaxAckPending: batchSize,
+	})
 
 	if err != nil {
-		return fmt.Errorf("consumer editEvent creation error: %w", err)
+		return fmt.Errorf("consumer allocation creation error: %w", err)
 	}
 
 	return nil
 }
 
-func (n *NATSIns) ConsumeEditEvent(ctx context.Context) error{
-	c, err := n.js.Consumer(ctx, "EVENT", "edit-event-worker");
+// ConsumeBookingRequests fetches up to batchSize queued requests at a time
+// and hands them to allocate together, the batch is acked only once
+// allocate succeeds (so allocate must be safe to run again on redelivery).
+// Requests dead-lettered after maxDeliver are handed to reject.
+func (n *NATSIns) ConsumeBookingRequests(ctx context.Context, batchSize int, allocate func(context.Context, []models.AsyncBookingRequest) error, reject func(context.Context, models.AsyncBookingRequest, error)) error {
+	c, err := n.js.Consumer(ctx, "ALLOCATIONS", "booking-allocator")
 	if err != nil {
 		return fmt.Errorf("get consumer error: %w", err)
 	}
-	fmt.Println("edit event consumer starting...")
 
 	for {
 		select {
-		case <- ctx.Done():
-			log.Println("shutting down edit-evnt event consumer...")
+		case <-ctx.Done():
+			log.Println("shutting down booking allocator...")
 			return nil
-		default: 
-			msgs, err := c.Fetch(1, jetstream.FetchMaxWait(5*time.Second));
-			if err != nil{
+		default:
+			msgs, err := c.Fetch(batchSize, jetstream.FetchMaxWait(time.Second))
+			if err != nil {
 				if err == jetstream.ErrNoMessages {
 					continue
 				}
@@ -254,14 +380,33 @@ func (n *NATSIns) ConsumeEditEvent(ctx context.Context) error{
 				continue
 			}
 
-			for msg := range msgs.Messages(){
-				if err := processEditEventMessage(msg.Data()); err != nil{
-					log.Printf("error in processing edit-event data: %v", err)
-					_ = msg.NakWithDelay(10 * time.Second)
-					continue 
+			var batch []jetstream.Msg
+			var reqs []models.AsyncBookingRequest
+			for msg := range msgs.Messages() {
+				var req models.AsyncBookingRequest
+				if err := json.Unmarshal(msg.Data(), &req); err != nil {
+					log.Printf("invalid booking request payload: %v", err)
+					n.deadLetter(ctx, msg, "invalid booking request payload: "+err.Error())
+					continue
 				}
+				batch = append(batch, msg)
+				reqs = append(reqs, req)
+			}
+			if len(reqs) == 0 {
+				continue
+			}
+
+			if err := allocate(ctx, reqs); err != nil {
+				log.Printf("error in allocating %d booking requests: %v", len(reqs), err)
+				for i, msg := range batch {
+					if n.retryOrDeadLetter(ctx, msg, err) {
+						reject(ctx, reqs[i], err)
+					}
+				}
+				continue
+			}
 
-				// acknowledges i.e message consumed
+			for _, msg := range batch {
 				if err := msg.Ack(); err != nil {
 					log.Println("ack failed:", err)
 				}
@@ -269,18 +414,3 @@ func (n *NATSIns) ConsumeEditEvent(ctx context.Context) error{
 		}
 	}
 }
-
-func processEditEventMessage(msg []byte) error{
-	var payload models.EventEditedPayload;
-	if err := json.Unmarshal(msg, &payload); err != nil {
-		log.Printf("invalid event edit payload: %v", err)
-	}
-
-	for _, email := range payload.To{
-		if err := mail.SendEditEventMail(email, payload); err != nil{
-			return err
-		}
-		fmt.Printf("event(%d) update mail send to %s", payload.EventID, email)
-	}
-	return nil;
-}
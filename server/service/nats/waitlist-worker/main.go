@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/nats"
+)
+
+func main() {
+	nats.RunMailWorker("waitlist-worker", bus.SubjectWaitlistOffer, nats.WaitlistHandler)
+}
@@ -0,0 +1,64 @@
+package nats
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/mail"
+)
+
+// RunWorker is the main of the workers consuming a single subject: it sets
+// up the stream of subject & the dead-letter stream, then runs h as
+// durable on subject until SIGTERM
+func RunWorker(durable, subject string, h bus.Handler) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	natsIns, err := NewNATSIns()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Safe to call (idempotent)
+	if err := natsIns.createStreamOf(ctx, subject); err != nil {
+		log.Fatal("stream creation failed:", err)
+	}
+	if err := natsIns.CreateDeadLetterStream(ctx); err != nil {
+		log.Fatal("stream creation failed:", err)
+	}
+
+	log.Printf("%s started", durable)
+	if err := natsIns.Subscribe(ctx, subject, durable, h); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RunMailWorker is RunWorker for the workers that only mail what comes in,
+// the handler is made by newHandler with the mailer from the env
+func RunMailWorker(durable, subject string, newHandler func(mail.Mailer) bus.Handler) {
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	RunWorker(durable, subject, newHandler(mailer))
+}
+
+// createStreamOf creates the stream subject is stored in
+func (n *NATSIns) createStreamOf(ctx context.Context, subject string) error {
+	stream, err := streamOf(subject)
+	if err != nil {
+		return err
+	}
+	switch stream {
+	case "BOOKINGS":
+		return n.CreateBookingStream(ctx)
+	case "ALLOCATIONS":
+		return n.CreateAllocationStream(ctx)
+	default:
+		return n.CreateEventStream(ctx)
+	}
+}
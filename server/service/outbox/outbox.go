@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/yeshu2004/go-event-booking/service/bus"
+)
+
+const (
+	maxBackoff = 5 * time.Minute
+	// delivered rows are kept for a while to look into
+	keepDelivered = 7 * 24 * time.Hour
+)
+
+// Execer is satisfied by *sql.Tx, messages are added in the caller's tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Add writes a message to the outbox, it is only published if the tx
+// commits. Adding the same msgID twice keeps the first message.
+func Add(ctx context.Context, tx Execer, subject, msgID string, payload []byte) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO outbox (subject, msg_id, payload) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE id = id", subject, msgID, payload)
+	return err
+}
+
+// Relay publishes pending outbox messages, several relays can run at
+// once as rows are claimed with SKIP LOCKED
+type Relay struct {
+	db        *sql.DB
+	pub       bus.Publisher
+	batchSize int
+}
+
+func NewRelay(db *sql.DB, pub bus.Publisher, batchSize int) *Relay {
+	return &Relay{db: db, pub: pub, batchSize: batchSize}
+}
+
+// Run relays pending messages every interval until ctx is done, a full
+// batch is followed right away by the next one
+func (r *Relay) Run(ctx context.Context, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		n, err := r.RelayPending(ctx)
+		if err != nil {
+			log.Printf("outbox relay error: %v", err)
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			if err := r.prune(ctx); err != nil {
+				log.Printf("outbox prune error: %v", err)
+			}
+			lastPrune = time.Now()
+		}
+
+		if err == nil && n == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("shutting down outbox relay...")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending publishes one batch of due messages and returns how many
+// were picked up, failed ones are retried later with backoff
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, subject, msg_id, payload, attempts FROM outbox
+		WHERE delivered_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type message struct {
+		id       int64
+		subject  string
+		msgID    string
+		payload  []byte
+		attempts int
+	}
+	var msgs []message
+	for rows.Next() {
+		var m message
+		if err := rows.Scan(&m.id, &m.subject, &m.msgID, &m.payload, &m.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		msgs = append(msgs, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, m := range msgs {
+		// the bus drops a re-publish of the same msg id, so a crash
+		// between publish & commit doesn't send the message twice
+		if err := r.pub.Publish(ctx, m.subject, m.msgID, m.payload); err != nil {
+			log.Printf("failed to relay outbox message %d (%s): %v", m.id, m.msgID, err)
+			next := time.Now().Add(backoff(m.attempts + 1))
+			if _, err := tx.ExecContext(ctx, "UPDATE outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?", truncate(err.Error(), 255), next, m.id); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET attempts = attempts + 1, last_error = NULL, delivered_at = ? WHERE id = ?", time.Now(), m.id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(msgs), nil
+}
+
+func (r *Relay) prune(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM outbox WHERE delivered_at < ? LIMIT 1000", time.Now().Add(-keepDelivered))
+	return err
+}
+
+// backoff doubles from 1s up to maxBackoff
+func backoff(attempts int) time.Duration {
+	if attempts > 9 {
+		return maxBackoff
+	}
+	d := time.Second << (attempts - 1)
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
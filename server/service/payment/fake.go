@@ -0,0 +1,182 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeConfig controls how the fake gateway behaves
+type FakeConfig struct {
+	FailPayments  bool          // Confirm fails every payment
+	FailRefunds   bool          // Refund fails every refund
+	Delay         time.Duration // latency added to every call
+	WebhookSecret string        // key used to sign & verify webhooks, none verify without it
+}
+
+// FakeGateway is an in memory gateway for local development and tests,
+// nothing leaves the process
+type FakeGateway struct {
+	cfg FakeConfig
+
+	mu      sync.Mutex
+	intents map[string]*Intent
+	refunds map[string]*Refund // by idempotency key
+}
+
+func NewFakeGateway(cfg FakeConfig) *FakeGateway {
+	return &FakeGateway{
+		cfg:     cfg,
+		intents: make(map[string]*Intent),
+		refunds: make(map[string]*Refund),
+	}
+}
+
+func (g *FakeGateway) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	intent := &Intent{
+		ID:           "pi_fake_" + randomHex(12),
+		BookingID:    req.BookingID,
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Status:       StatusPending,
+		ClientSecret: "secret_fake_" + randomHex(12),
+	}
+
+	g.mu.Lock()
+	g.intents[intent.ID] = intent
+	g.mu.Unlock()
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *FakeGateway) Confirm(ctx context.Context, intentID string) (*Intent, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+
+	// confirming again returns the first outcome
+	if intent.Status == StatusPending {
+		if g.cfg.FailPayments {
+			intent.Status = StatusFailed
+			intent.FailureReason = "card declined (fake gateway)"
+		} else {
+			intent.Status = StatusSucceeded
+		}
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *FakeGateway) Cancel(ctx context.Context, intentID string) (*Intent, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status == StatusPending {
+		intent.Status = StatusCancelled
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+// Refund does not look the intent up, refunds may be processed by a
+// different process (refund worker) than the one that took the payment
+func (g *FakeGateway) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if refund, ok := g.refunds[req.Key]; ok && req.Key != "" {
+		copied := *refund
+		return &copied, nil
+	}
+
+	refund := &Refund{
+		ID:       "re_fake_" + randomHex(12),
+		IntentID: req.IntentID,
+		Amount:   req.Amount,
+		Status:   StatusSucceeded,
+	}
+	if g.cfg.FailRefunds {
+		refund.Status = StatusFailed
+		refund.FailureReason = "refund rejected (fake gateway)"
+	}
+	if req.Key != "" {
+		g.refunds[req.Key] = refund
+	}
+
+	copied := *refund
+	return &copied, nil
+}
+
+func (g *FakeGateway) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if g.cfg.WebhookSecret == "" || !hmac.Equal([]byte(g.SignWebhook(payload)), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// SignWebhook signs a payload the way VerifyWebhook expects it, handy to
+// simulate provider callbacks locally
+func (g *FakeGateway) SignWebhook(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(g.cfg.WebhookSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (g *FakeGateway) wait(ctx context.Context) error {
+	if g.cfg.Delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(g.cfg.Delay):
+		return nil
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
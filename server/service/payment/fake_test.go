@@ -0,0 +1,230 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFakeIntentTransitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        FakeConfig
+		wantStatus Status
+		wantReason bool
+	}{
+		{name: "succeeds", wantStatus: StatusSucceeded},
+		{name: "fails", cfg: FakeConfig{FailPayments: true}, wantStatus: StatusFailed, wantReason: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := NewFakeGateway(tt.cfg)
+
+			intent, err := g.CreateIntent(ctx, IntentRequest{BookingID: 3, Amount: 50000, Currency: "INR"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if intent.Status != StatusPending || intent.BookingID != 3 || intent.Amount != 50000 || intent.ClientSecret == "" {
+				t.Fatalf("created intent = %+v", intent)
+			}
+
+			confirmed, err := g.Confirm(ctx, intent.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if confirmed.Status != tt.wantStatus || (confirmed.FailureReason != "") != tt.wantReason {
+				t.Fatalf("confirmed intent = %+v, want status %s", confirmed, tt.wantStatus)
+			}
+
+			// confirming again keeps the first outcome
+			g.cfg.FailPayments = !g.cfg.FailPayments
+			again, err := g.Confirm(ctx, intent.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Status != tt.wantStatus {
+				t.Errorf("confirmed again = %s, want %s", again.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFakeCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirm    bool // confirmed before the cancel
+		cfg        FakeConfig
+		wantStatus Status
+	}{
+		{name: "pending is voided", wantStatus: StatusCancelled},
+		{name: "paid stays paid", confirm: true, wantStatus: StatusSucceeded},
+		{name: "failed stays failed", confirm: true, cfg: FakeConfig{FailPayments: true}, wantStatus: StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := NewFakeGateway(tt.cfg)
+			intent, err := g.CreateIntent(ctx, IntentRequest{BookingID: 1, Amount: 100, Currency: "INR"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.confirm {
+				if _, err := g.Confirm(ctx, intent.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cancelled, err := g.Cancel(ctx, intent.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cancelled.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", cancelled.Status, tt.wantStatus)
+			}
+
+			// a voided intent can't be paid afterwards
+			confirmed, err := g.Confirm(ctx, intent.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if confirmed.Status != tt.wantStatus {
+				t.Errorf("confirmed after cancel = %s, want %s", confirmed.Status, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, err := NewFakeGateway(FakeConfig{}).Cancel(context.Background(), "pi_missing"); err != ErrIntentNotFound {
+		t.Errorf("unknown intent: error = %v, want %v", err, ErrIntentNotFound)
+	}
+}
+
+func TestFakeConfirmUnknownIntent(t *testing.T) {
+	g := NewFakeGateway(FakeConfig{})
+	if _, err := g.Confirm(context.Background(), "pi_missing"); err != ErrIntentNotFound {
+		t.Errorf("error = %v, want %v", err, ErrIntentNotFound)
+	}
+}
+
+func TestFakeRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        FakeConfig
+		keys       [2]string
+		wantStatus Status
+		wantSame   bool // second call returns the first refund
+	}{
+		{name: "same key refunded once", keys: [2]string{"refund-1", "refund-1"}, wantStatus: StatusSucceeded, wantSame: true},
+		{name: "different keys", keys: [2]string{"refund-1", "refund-2"}, wantStatus: StatusSucceeded},
+		{name: "no key", keys: [2]string{"", ""}, wantStatus: StatusSucceeded},
+		{name: "failing gateway", cfg: FakeConfig{FailRefunds: true}, keys: [2]string{"refund-1", "refund-1"}, wantStatus: StatusFailed, wantSame: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := NewFakeGateway(tt.cfg)
+
+			first, err := g.Refund(ctx, RefundRequest{IntentID: "pi_1", Amount: 2500, Key: tt.keys[0]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := g.Refund(ctx, RefundRequest{IntentID: "pi_1", Amount: 2500, Key: tt.keys[1]})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if first.Status != tt.wantStatus || first.Amount != 2500 || first.IntentID != "pi_1" {
+				t.Errorf("refund = %+v, want status %s", first, tt.wantStatus)
+			}
+			if (first.ID == second.ID) != tt.wantSame {
+				t.Errorf("refund ids %s & %s, want same = %v", first.ID, second.ID, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestFakeWebhook(t *testing.T) {
+	g := NewFakeGateway(FakeConfig{WebhookSecret: "whsec"})
+	payload, _ := json.Marshal(WebhookEvent{Type: WebhookPaymentSucceeded, IntentID: "pi_1", BookingID: 9})
+	sig := g.SignWebhook(payload)
+
+	event, err := g.VerifyWebhook(payload, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != WebhookPaymentSucceeded || event.IntentID != "pi_1" || event.BookingID != 9 {
+		t.Errorf("event = %+v", event)
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		sig     string
+	}{
+		{name: "no signature", payload: payload},
+		{name: "payload changed", payload: append(payload[:len(payload):len(payload)], ' '), sig: sig},
+		{name: "other secret", payload: payload, sig: NewFakeGateway(FakeConfig{WebhookSecret: "other"}).SignWebhook(payload)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.VerifyWebhook(tt.payload, tt.sig); err != ErrInvalidSignature {
+				t.Errorf("error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	// without a secret nothing verifies, not even an empty signature
+	unset := NewFakeGateway(FakeConfig{})
+	if _, err := unset.VerifyWebhook(payload, unset.SignWebhook(payload)); err != ErrInvalidSignature {
+		t.Errorf("no secret: error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestNewGatewayFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway string
+		secret  string
+		wantErr error
+	}{
+		{name: "fake", gateway: "fake", secret: "whsec"},
+		{name: "no gateway", secret: "whsec", wantErr: ErrNoGateway},
+		{name: "no secret", gateway: "fake", wantErr: ErrNoWebhookSecret},
+		{name: "nothing set", wantErr: ErrNoWebhookSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PAYMENT_GATEWAY", tt.gateway)
+			t.Setenv("PAYMENT_WEBHOOK_SECRET", tt.secret)
+
+			g, err := NewGatewayFromEnv()
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && g == nil {
+				t.Error("no gateway returned")
+			}
+		})
+	}
+
+	t.Setenv("PAYMENT_GATEWAY", "stripe")
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "whsec")
+	if _, err := NewGatewayFromEnv(); err == nil {
+		t.Error("unknown gateway accepted")
+	}
+}
+
+func TestFakeDelayHonoursContext(t *testing.T) {
+	g := NewFakeGateway(FakeConfig{Delay: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := g.CreateIntent(ctx, IntentRequest{BookingID: 1}); err != context.Canceled {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
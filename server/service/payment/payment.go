@@ -0,0 +1,119 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Gateway is the payment step of the booking flow, implemented by the
+// payment provider in use (only the fake gateway for now)
+type Gateway interface {
+	// CreateIntent starts a payment for a booking
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// Confirm captures the payment of an intent
+	Confirm(ctx context.Context, intentID string) (*Intent, error)
+	// Cancel voids an intent that is not paid yet, an intent already
+	// paid (or failed) is returned as it is
+	Cancel(ctx context.Context, intentID string) (*Intent, error)
+	// Refund gives back (part of) a captured payment
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+	// VerifyWebhook checks the signature of a provider callback and parses it
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusSucceeded Status = "SUCCEEDED"
+	StatusFailed    Status = "FAILED"
+	StatusCancelled Status = "CANCELLED"
+)
+
+const (
+	WebhookPaymentSucceeded string = "payment.succeeded"
+	WebhookPaymentFailed    string = "payment.failed"
+)
+
+var (
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrNoGateway        = errors.New("PAYMENT_GATEWAY is not set")
+	ErrNoWebhookSecret  = errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+)
+
+type IntentRequest struct {
+	BookingID int64
+	Amount    int64 // minor units i.e paise/cents
+	Currency  string
+}
+
+type Intent struct {
+	ID            string `json:"id"`
+	BookingID     int64  `json:"booking_id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Status        Status `json:"status"`
+	ClientSecret  string `json:"client_secret,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+type RefundRequest struct {
+	IntentID string
+	Amount   int64 // minor units
+	Reason   string
+	// Key makes retries safe, a key is refunded at most once
+	Key string
+}
+
+type Refund struct {
+	ID            string `json:"id"`
+	IntentID      string `json:"intent_id"`
+	Amount        int64  `json:"amount"`
+	Status        Status `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// WebhookEvent is a verified callback from the provider
+type WebhookEvent struct {
+	Type      string `json:"type"`
+	IntentID  string `json:"intent_id"`
+	BookingID int64  `json:"booking_id"`
+	Amount    int64  `json:"amount"` // captured, minor units
+	Currency  string `json:"currency"`
+}
+
+// NewGatewayFromEnv returns the gateway picked by PAYMENT_GATEWAY, there
+// is no default: the fake gateway approves every payment so it has to be
+// asked for with PAYMENT_GATEWAY=fake. Webhooks are signed with
+// PAYMENT_WEBHOOK_SECRET, it must be set.
+func NewGatewayFromEnv() (Gateway, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, ErrNoWebhookSecret
+	}
+
+	switch name := os.Getenv("PAYMENT_GATEWAY"); name {
+	case "":
+		return nil, ErrNoGateway
+	case "fake":
+		cfg := FakeConfig{
+			FailPayments:  os.Getenv("FAKE_PAYMENT_FAIL") == "true",
+			FailRefunds:   os.Getenv("FAKE_REFUND_FAIL") == "true",
+			WebhookSecret: secret,
+		}
+		if d := os.Getenv("FAKE_PAYMENT_DELAY"); d != "" {
+			delay, err := time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("invalid FAKE_PAYMENT_DELAY: %w", err)
+			}
+			cfg.Delay = delay
+		}
+		return NewFakeGateway(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway: %q", name)
+	}
+}
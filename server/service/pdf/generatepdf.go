@@ -1,11 +1,13 @@
 package pdf
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
 	"github.com/yeshu2004/go-event-booking/models"
 )
 
@@ -15,6 +17,11 @@ func GenerateBookingPDF(bookingData *models.PDFContent) (string, error) {
 		formattedTime = bookingData.EventDateTime.Format("02 Jan 2006, 03:04 PM")
 	}
 
+	tierName := bookingData.TierName
+	if tierName == "" {
+		tierName = "General"
+	}
+
 	pdf := gofpdf.New("p", "mm", "A4", "")
 	pdf.AddPage()
 	pdf.SetFont("Helvetica", "", 16)
@@ -29,7 +36,9 @@ func GenerateBookingPDF(bookingData *models.PDFContent) (string, error) {
 	Booking Details:
 	- Event Name: %s
 	- Date & Time: %s
+	- Ticket: %s
 	- Seats Booked: %d
+	- Amount Paid: %s
 
 	Please keep this email as your booking confirmation. 
 	You can also access and download your receipt anytime from the My Bookings section in your profile
@@ -37,12 +46,57 @@ func GenerateBookingPDF(bookingData *models.PDFContent) (string, error) {
 	
 	We look forward to seeing you at the event!
 	Best regards,
-	Ticket One Team`, bookingData.UserName, bookingData.EventName, formattedTime, bookingData.SeatsBooked)
+	Ticket One Team`, bookingData.UserName, bookingData.EventName, formattedTime, tierName, bookingData.SeatsBooked, models.FormatAmount(bookingData.Amount, bookingData.Currency))
+
+	// re-issued ticket after a partial cancellation
+	if bookingData.SeatsCancelled > 0 {
+		letter += fmt.Sprintf(`
+
+	This ticket replaces your previous one, %d seat(s) were cancelled.
+	Only the %d seat(s) listed above are valid for entry.`, bookingData.SeatsCancelled, bookingData.SeatsBooked)
+	}
 
 	pdf.MultiCell(0, 10, letter, "", "L", false)
+
+	// scanned by the organizer at the entry
+	if bookingData.TicketToken != "" {
+		if err := addTicketQR(pdf, bookingData.TicketToken); err != nil {
+			return "", err
+		}
+	}
+
 	log.Printf("PDF generated for booking %v", bookingData)
 
 	fileName := fmt.Sprintf("%d_event_ticket_%d.pdf", time.Now().UnixNano(), bookingData.BookingID)
 
 	return fileName, pdf.OutputFileAndClose(fileName)
-}
\ No newline at end of file
+}
+
+// addTicketQR renders the signed ticket token as a QR code below the letter
+func addTicketQR(pdf *gofpdf.Fpdf, token string) error {
+	png, err := qrcode.Encode(token, qrcode.Medium, 256)
+	if err != nil {
+		return fmt.Errorf("qr code error: %w", err)
+	}
+
+	const size = 60.0 // mm
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+size+20 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	pdf.Ln(5)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 10, "Show this code at the entry", "", 1, "C", false, 0, "")
+
+	opt := gofpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("ticket-qr", opt, bytes.NewReader(png))
+	pageWidth, _ := pdf.GetPageSize()
+	pdf.ImageOptions("ticket-qr", (pageWidth-size)/2, pdf.GetY(), size, size, true, opt, 0, "")
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.CellFormat(0, 6, token, "", 1, "C", false, 0, "")
+
+	return pdf.Error()
+}
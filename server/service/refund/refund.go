@@ -0,0 +1,92 @@
+package refund
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yeshu2004/go-event-booking/models"
+	"github.com/yeshu2004/go-event-booking/service/mail"
+	"github.com/yeshu2004/go-event-booking/service/payment"
+)
+
+// Processor gives back the money of a refund row through the payment
+// gateway and tells the user once it is done
+type Processor struct {
+	db      *sql.DB
+	gateway payment.Gateway
+	mailer  mail.Mailer
+}
+
+func NewProcessor(db *sql.DB, gateway payment.Gateway, mailer mail.Mailer) *Processor {
+	return &Processor{db: db, gateway: gateway, mailer: mailer}
+}
+
+// Process is safe to run more than once for the same refund, finished
+// refunds are skipped and the gateway call is keyed by the refund id
+func (p *Processor) Process(ctx context.Context, data models.RefundPayload) error {
+	var r models.Refund
+	query := "SELECT id, booking_id, payment_intent_id, amount, currency, percent, reason, status FROM refund WHERE id = ?"
+	err := p.db.QueryRowContext(ctx, query, data.RefundID).Scan(&r.Id, &r.BookingId, &r.PaymentIntentID, &r.Amount, &r.Currency, &r.Percent, &r.Reason, &r.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("refund %d not found, skipping", data.RefundID)
+			return nil
+		}
+		return err
+	}
+
+	if r.Status != "PENDING" {
+		log.Printf("refund %d already %s, skipping", r.Id, r.Status)
+		return nil
+	}
+
+	reason := "booking cancelled"
+	if r.Reason == "UNPAYABLE" {
+		reason = "booking no longer payable"
+	}
+
+	res, err := p.gateway.Refund(ctx, payment.RefundRequest{
+		IntentID: r.PaymentIntentID,
+		Amount:   r.Amount,
+		Reason:   reason,
+		Key:      fmt.Sprintf("refund-%d", r.Id),
+	})
+	if err != nil {
+		return fmt.Errorf("gateway refund error: %w", err)
+	}
+
+	now := time.Now()
+	if res.Status != payment.StatusSucceeded {
+		// rejected by the gateway, needs a look from support
+		_, err := p.db.ExecContext(ctx, "UPDATE refund SET status = 'FAILED', gateway_refund_id = ?, failure_reason = ?, completed_at = ? WHERE id = ? AND status = 'PENDING'", res.ID, res.FailureReason, now, r.Id)
+		if err != nil {
+			return err
+		}
+		log.Printf("refund %d failed: %s", r.Id, res.FailureReason)
+		return nil
+	}
+
+	result, err := p.db.ExecContext(ctx, "UPDATE refund SET status = 'SUCCEEDED', gateway_refund_id = ?, completed_at = ? WHERE id = ? AND status = 'PENDING'", res.ID, now, r.Id)
+	if err != nil {
+		return err
+	}
+	// another delivery already finished it and sent the mail
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+	log.Printf("refund %d of %s succeeded", r.Id, models.FormatAmount(r.Amount, r.Currency))
+
+	r.Status = "SUCCEEDED"
+	r.GatewayRefundID = &res.ID
+	r.CompletedAt = &now
+	if err := mail.SendRefundMail(ctx, p.mailer, data, r); err != nil {
+		// money is back already, a retry would only resend the mail
+		log.Printf("failed to send refund mail for refund %d: %v", r.Id, err)
+		return nil
+	}
+	log.Printf("refund email sent to %s for booking ID %d", data.UserEmail, data.BookingID)
+	return nil
+}
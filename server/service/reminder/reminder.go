@@ -0,0 +1,182 @@
+package reminder
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yeshu2004/go-event-booking/models"
+	"github.com/yeshu2004/go-event-booking/service/bus"
+	"github.com/yeshu2004/go-event-booking/service/outbox"
+)
+
+// Offsets are how long before the event a reminder is sent
+var Offsets = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+// Schedule adds the reminders of a confirmed booking in the caller's tx,
+// offsets already in the past are left out. Scheduling a booking again
+// keeps its reminders as they are.
+func Schedule(ctx context.Context, tx outbox.Execer, bookingID, eventID int64) error {
+	for _, offset := range Offsets {
+		minutes := int64(offset / time.Minute)
+		_, err := tx.ExecContext(ctx, `INSERT INTO reminder (booking_id, event_id, minutes_before, send_at)
+			SELECT ?, e.id, ?, e.date - INTERVAL ? MINUTE FROM event e
+			WHERE e.id = ? AND e.date - INTERVAL ? MINUTE > NOW()
+			ON DUPLICATE KEY UPDATE id = id`, bookingID, minutes, minutes, eventID, minutes)
+		if err != nil {
+			return fmt.Errorf("schedule reminder: %w", err)
+		}
+	}
+	return nil
+}
+
+// RearmAfter is how far the send time of a reminder already sent has to
+// move for it to be sent again, small date fixes don't mail everyone twice
+var RearmAfter = 6 * time.Hour
+
+// Reschedule moves the reminders of an event to its current date, called
+// in the tx that changed the date. A reminder already sent keeps the
+// send_at it was sent for and is only sent again when the new time is at
+// least RearmAfter away from it, the ones whose new time has passed are
+// skipped.
+func Reschedule(ctx context.Context, tx outbox.Execer, eventID int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE reminder r JOIN event e ON e.id = r.event_id
+		SET r.status = 'SKIPPED'
+		WHERE r.event_id = ? AND r.status = 'PENDING' AND e.date - INTERVAL r.minutes_before MINUTE <= NOW()`, eventID)
+	if err != nil {
+		return fmt.Errorf("skip reminders: %w", err)
+	}
+
+	rearm := int64(RearmAfter / time.Minute)
+	_, err = tx.ExecContext(ctx, `UPDATE reminder r JOIN event e ON e.id = r.event_id
+		SET r.send_at = e.date - INTERVAL r.minutes_before MINUTE, r.status = 'PENDING', r.sent_at = NULL
+		WHERE r.event_id = ? AND e.date - INTERVAL r.minutes_before MINUTE > NOW()
+			AND (r.status = 'PENDING' OR (r.status = 'SENT'
+				AND ABS(TIMESTAMPDIFF(MINUTE, r.send_at, e.date - INTERVAL r.minutes_before MINUTE)) >= ?))`, eventID, rearm)
+	if err != nil {
+		return fmt.Errorf("reschedule reminders: %w", err)
+	}
+	return nil
+}
+
+// Cancel drops the pending reminders of a cancelled booking
+func Cancel(ctx context.Context, tx outbox.Execer, bookingID int64) error {
+	_, err := tx.ExecContext(ctx, "UPDATE reminder SET status = 'CANCELLED' WHERE booking_id = ? AND status = 'PENDING'", bookingID)
+	return err
+}
+
+// Scheduler hands due reminders to the mail worker through the outbox,
+// several schedulers can run at once as rows are claimed with SKIP LOCKED
+// and a reminder is marked sent in the same tx it is queued in
+type Scheduler struct {
+	db        *sql.DB
+	batchSize int
+}
+
+func NewScheduler(db *sql.DB, batchSize int) *Scheduler {
+	return &Scheduler{db: db, batchSize: batchSize}
+}
+
+// Run queues due reminders every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.SendDue(ctx)
+		if err != nil {
+			log.Printf("reminder scheduler error: %v", err)
+		}
+
+		if err == nil && n == s.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("shutting down reminder scheduler...")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue queues one batch of due reminders and returns how many were
+// picked up
+func (s *Scheduler) SendDue(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT r.id, r.booking_id, r.event_id, r.minutes_before, r.send_at, b.status, b.seats,
+			u.first_name, u.email, e.name, e.date, e.address, e.city, e.visible
+		FROM reminder r
+		JOIN booking b ON b.id = r.booking_id
+		JOIN user u ON u.id = b.user_id
+		JOIN event e ON e.id = r.event_id
+		WHERE r.status = 'PENDING' AND r.send_at <= NOW()
+		ORDER BY r.send_at LIMIT ? FOR UPDATE OF r SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, s.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type due struct {
+		payload       models.ReminderPayload
+		sendAt        time.Time
+		minutesBefore int64
+		bookingStatus string
+		visible       string
+		address, city string
+	}
+	var reminders []due
+	for rows.Next() {
+		var d due
+		p := &d.payload
+		if err := rows.Scan(&p.ReminderID, &p.BookingID, &p.EventID, &d.minutesBefore, &d.sendAt, &d.bookingStatus, &p.Seats,
+			&p.UserName, &p.UserEmail, &p.EventName, &p.EventDate, &d.address, &d.city, &d.visible); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		reminders = append(reminders, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, d := range reminders {
+		// booking cancelled or event gone/over without the reminder
+		// being cancelled, nothing to remind of
+		if d.bookingStatus != "CONFIRMED" || d.visible == "DELETED" || !d.payload.EventDate.After(now) {
+			if _, err := tx.ExecContext(ctx, "UPDATE reminder SET status = 'SKIPPED' WHERE id = ?", d.payload.ReminderID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		d.payload.Location = fmt.Sprintf("%s, %s", d.address, d.city)
+		d.payload.HoursBefore = d.minutesBefore / 60
+		p, err := json.Marshal(d.payload)
+		if err != nil {
+			return 0, err
+		}
+		if err := outbox.Add(ctx, tx, bus.SubjectEventReminder, bus.ReminderMsgID(d.payload.ReminderID, d.sendAt), p); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE reminder SET status = 'SENT', sent_at = ? WHERE id = ?", now, d.payload.ReminderID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(reminders), nil
+}
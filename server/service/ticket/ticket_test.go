@@ -0,0 +1,82 @@
+package ticket
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	t.Setenv("TICKET_SECRET", "test-secret")
+	if err := CheckSecret(); err != nil {
+		t.Fatalf("CheckSecret: %v", err)
+	}
+
+	token, err := Sign(42, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Sign(42, 7); again != token {
+		t.Errorf("re-signed token = %q, want %q", again, token)
+	}
+
+	claims, err := Verify(" " + token + "\n")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.BookingID != 42 || claims.EventID != 7 {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// same token signed by someone else
+	parts := strings.Split(token, ".")
+	t.Setenv("TICKET_SECRET", "other-secret")
+	forged, _ := Sign(42, 7)
+	t.Setenv("TICKET_SECRET", "test-secret")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty", token: ""},
+		{name: "other secret", token: forged},
+		{name: "booking changed", token: strings.Join([]string{parts[0], "43", parts[2], parts[3]}, ".")},
+		{name: "event changed", token: strings.Join([]string{parts[0], parts[1], "8", parts[3]}, ".")},
+		{name: "signature changed", token: strings.Join([]string{parts[0], parts[1], parts[2], "AAAA"}, ".")},
+		{name: "unknown version", token: strings.Join([]string{"t2", parts[1], parts[2], parts[3]}, ".")},
+		{name: "missing part", token: strings.Join(parts[:3], ".")},
+		{name: "extra part", token: token + ".x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.token); err != ErrInvalidToken {
+				t.Errorf("Verify(%q) error = %v, want %v", tt.token, err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestNoSecret(t *testing.T) {
+	t.Setenv("TICKET_SECRET", "")
+
+	if err := CheckSecret(); err != ErrNoSecret {
+		t.Errorf("CheckSecret error = %v, want %v", err, ErrNoSecret)
+	}
+	if _, err := Sign(1, 1); err != ErrNoSecret {
+		t.Errorf("Sign error = %v, want %v", err, ErrNoSecret)
+	}
+	if _, err := Verify("t1.1.1.x"); err != ErrNoSecret {
+		t.Errorf("Verify error = %v, want %v", err, ErrNoSecret)
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("t1.1.2.abc") != Hash(" t1.1.2.abc\n") {
+		t.Error("hash depends on surrounding spaces")
+	}
+	if Hash("t1.1.2.abc") == Hash("t1.1.3.abc") {
+		t.Error("different tokens share a hash")
+	}
+	if h := Hash("t1.1.2.abc"); len(h) != 64 || strings.Contains(h, "abc") {
+		t.Errorf("hash = %q, want hex sha256", h)
+	}
+}
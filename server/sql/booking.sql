@@ -3,10 +3,26 @@ CREATE TABLE IF NOT EXISTS booking (
     event_id  INT NOT NULL,
     user_id   INT NOT NULL,
     seats     INT NOT NULL,
+    tier_id   INT NULL,
+    amount    INT NOT NULL DEFAULT 0, -- minor units i.e paise/cents
+    currency  CHAR(3) NOT NULL DEFAULT "INR",
+    status    ENUM("PENDING_PAYMENT", "CONFIRMED", "CANCELLED", "EXPIRED") DEFAULT "CONFIRMED",
     booked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     pdf_key   VARCHAR(200),
+    payment_intent_id  VARCHAR(100),
+    payment_expires_at TIMESTAMP NULL, -- unpaid bookings expire & release seats
+    paid_at            TIMESTAMP NULL,
+    checked_in_at      TIMESTAMP NULL, -- set once the ticket is scanned at the entry
+    checked_in_gate    VARCHAR(50),
+    request_id         VARCHAR(64) NULL, -- async booking request that created it
+    hold_id            VARCHAR(64) NULL, -- seat hold confirmed into it
     FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE,
-    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE,
+    FOREIGN KEY (tier_id) REFERENCES ticket_tier(id) ON DELETE SET NULL,
     INDEX idx_event (event_id),
-    INDEX idx_user (user_id)
-);
\ No newline at end of file
+    INDEX idx_user (user_id),
+    INDEX idx_status_expiry (status, payment_expires_at),
+    INDEX idx_payment_intent (payment_intent_id),
+    UNIQUE KEY uniq_request (request_id),
+    UNIQUE KEY uniq_hold (hold_id)
+);
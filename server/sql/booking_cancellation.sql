@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS booking_cancellation (
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    booking_id      INT NOT NULL,
+    seats           INT NOT NULL CHECK (seats > 0),
+    remaining_seats INT NOT NULL CHECK (remaining_seats >= 0),
+    cancelled_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES booking(id) ON DELETE CASCADE,
+    INDEX idx_booking (booking_id)
+);
@@ -2,6 +2,7 @@
 CREATE TABLE event (
     id INT AUTO_INCREMENT PRIMARY KEY,
     name VARCHAR(200) NOT NULL,
+    description VARCHAR(2000) NOT NULL DEFAULT '',
     org_id INT NOT NULL,
     organized_by VARCHAR(200) NOT NULL,
     capacity INT NOT NULL CHECK (capacity >= 0),
@@ -11,11 +12,21 @@ CREATE TABLE event (
     city VARCHAR(200) NOT NULL,
     state VARCHAR(200) NOT NULL,
     country VARCHAR(200) NOT NULL,
+    latitude DECIMAL(9, 6) NULL, -- set by the organizer or geocoded from the address
+    longitude DECIMAL(9, 6) NULL,
     image_key VARCHAR(200),
-    visible ENUM("PUBLIC", "PRIVATE", "DELETED") DEFAULT "PUBLIC",
+    visible ENUM("PUBLIC", "PRIVATE", "DELETED", "CANCELLED") DEFAULT "PUBLIC",
+    max_seats_per_booking INT NULL CHECK (max_seats_per_booking > 0), -- NULL = no limit
+    max_seats_per_user INT NULL CHECK (max_seats_per_user > 0),
+    max_bookings_per_user INT NULL CHECK (max_bookings_per_user > 0),
+    cancelled_at TIMESTAMP NULL, -- set when the organizer cancels the event
+    cancel_reason VARCHAR(500),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (org_id) REFERENCES organization(id) ON DELETE CASCADE, 
-    CONSTRAINT chk_seats CHECK (seats_available <= capacity)
+    CONSTRAINT chk_seats CHECK (seats_available <= capacity),
+    FULLTEXT KEY ft_search (name, organized_by, address, description), -- /api/events/search
+    INDEX idx_visible_date (visible, date),
+    INDEX idx_geo (latitude, longitude) -- bounding box of /api/events/near
 );
 
 DELIMITER //
@@ -0,0 +1,15 @@
+-- progress of an organizer cancelling an event, the bookings are
+-- cancelled & refunded in batches after the event is marked cancelled
+CREATE TABLE IF NOT EXISTS event_cancellation (
+    event_id             INT PRIMARY KEY,
+    reason               VARCHAR(500),
+    status               ENUM("IN_PROGRESS", "COMPLETED") DEFAULT "IN_PROGRESS",
+    bookings_total       INT NOT NULL DEFAULT 0,
+    bookings_cancelled   INT NOT NULL DEFAULT 0,
+    refunds_created      INT NOT NULL DEFAULT 0,
+    notifications_queued INT NOT NULL DEFAULT 0,
+    started_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at         TIMESTAMP NULL,
+    FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE,
+    INDEX idx_status (status)
+);
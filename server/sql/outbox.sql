@@ -0,0 +1,15 @@
+-- messages for NATS written in the same tx as the change they announce,
+-- published by the outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    subject         VARCHAR(100) NOT NULL,
+    msg_id          VARCHAR(128) NOT NULL, -- jetstream dedup id
+    payload         JSON NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      VARCHAR(255),
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at    TIMESTAMP NULL,
+    UNIQUE KEY uniq_msg (msg_id),
+    INDEX idx_pending (delivered_at, next_attempt_at)
+);
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS refund_policy (
+    event_id               INT PRIMARY KEY,
+    full_refund_days       INT NOT NULL DEFAULT 7 CHECK (full_refund_days >= 0),
+    partial_refund_percent INT NOT NULL DEFAULT 50 CHECK (partial_refund_percent BETWEEN 0 AND 100),
+    no_refund_hours        INT NOT NULL DEFAULT 24 CHECK (no_refund_hours >= 0),
+    updated_at             TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS refund (
+    id                INT AUTO_INCREMENT PRIMARY KEY,
+    booking_id        INT NOT NULL,
+    cancellation_id   INT NULL,
+    payment_intent_id VARCHAR(64) NOT NULL,
+    amount            INT NOT NULL CHECK (amount > 0),
+    currency          CHAR(3) NOT NULL,
+    percent           INT NOT NULL,
+    reason            ENUM("CANCELLED", "UNPAYABLE") NOT NULL DEFAULT "CANCELLED", -- UNPAYABLE: paid after the booking expired
+    status            ENUM("PENDING", "SUCCEEDED", "FAILED") DEFAULT "PENDING",
+    gateway_refund_id VARCHAR(64),
+    failure_reason    VARCHAR(255),
+    created_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at      TIMESTAMP NULL,
+    -- a payment captured for an unpayable booking is given back once
+    unpayable_intent_id VARCHAR(64) AS (IF(reason = "UNPAYABLE", payment_intent_id, NULL)) STORED,
+    FOREIGN KEY (booking_id) REFERENCES booking(id) ON DELETE CASCADE,
+    FOREIGN KEY (cancellation_id) REFERENCES booking_cancellation(id) ON DELETE SET NULL,
+    UNIQUE KEY uniq_cancellation (cancellation_id),
+    UNIQUE KEY uniq_unpayable_intent (unpayable_intent_id),
+    INDEX idx_booking (booking_id),
+    INDEX idx_status (status)
+);
@@ -0,0 +1,17 @@
+-- reminder mails of confirmed bookings, sent by the reminder scheduler
+-- some time before the event
+CREATE TABLE IF NOT EXISTS reminder (
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    booking_id     INT NOT NULL,
+    event_id       INT NOT NULL,
+    minutes_before INT NOT NULL, -- send_at = event.date - minutes_before
+    send_at        TIMESTAMP NOT NULL,
+    status         ENUM("PENDING", "SENT", "CANCELLED", "SKIPPED") DEFAULT "PENDING",
+    sent_at        TIMESTAMP NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES booking(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE,
+    UNIQUE KEY uniq_booking_offset (booking_id, minutes_before),
+    INDEX idx_due (status, send_at),
+    INDEX idx_event (event_id)
+);
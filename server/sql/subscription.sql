@@ -1,11 +1,11 @@
-CREATE TABLE subscription(
-    id INT AUTO_INCREMENT PRIMARY KEY,
-    user_id INT NOT NULL,
-    org_id INT NOT NULL,
-    subscribed BOOLEAN DEFAULT FALSE,
-    subscribed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    FOREIGN KEY (user_id) REFERENCES user(id) on DELETE CASCADE
-    FOREIGN KEY (currentUser) REFERENCES organization(id) on DELETE CASCADE
-    UNIQUE (user_id, org_id)
-)
-
+-- users following an organization, a row per follow
+CREATE TABLE IF NOT EXISTS subscription (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    user_id       INT NOT NULL,
+    org_id        INT NOT NULL,
+    subscribed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE,
+    FOREIGN KEY (org_id) REFERENCES organization(id) ON DELETE CASCADE,
+    UNIQUE KEY uniq_user_org (user_id, org_id),
+    INDEX idx_org (org_id)
+);
@@ -0,0 +1,18 @@
+-- categories (curated list in models.Categories) & free-form tags of
+-- events, a row per distinct name
+CREATE TABLE IF NOT EXISTS tag (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    kind       ENUM("CATEGORY", "TAG") NOT NULL,
+    name       VARCHAR(30) NOT NULL, -- lowercase, normalized by models.NormalizeTag
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_kind_name (kind, name)
+);
+
+CREATE TABLE IF NOT EXISTS event_tag (
+    event_id INT NOT NULL,
+    tag_id   INT NOT NULL,
+    PRIMARY KEY (event_id, tag_id),
+    FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tag(id) ON DELETE CASCADE,
+    INDEX idx_tag (tag_id, event_id) -- listing filtered by category/tag
+);
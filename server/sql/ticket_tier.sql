@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS ticket_tier (
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    event_id        INT NOT NULL,
+    name            VARCHAR(100) NOT NULL,
+    price           INT NOT NULL DEFAULT 0 CHECK (price >= 0), -- minor units i.e paise/cents
+    currency        CHAR(3) NOT NULL DEFAULT "INR",
+    capacity        INT NOT NULL CHECK (capacity >= 0),
+    seats_available INT NOT NULL CHECK (seats_available >= 0),
+    sale_starts_at  TIMESTAMP NULL,
+    sale_ends_at    TIMESTAMP NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE,
+    UNIQUE (event_id, name),
+    CONSTRAINT chk_tier_seats CHECK (seats_available <= capacity)
+);
@@ -0,0 +1,12 @@
+-- weekly digest settings of a user, last_period is the last week handled
+-- so a rerun of the job never sends the same week twice
+CREATE TABLE IF NOT EXISTS user_digest (
+    user_id      INT PRIMARY KEY,
+    opted_out    BOOLEAN NOT NULL DEFAULT FALSE,
+    city         VARCHAR(200),   -- NULL = city of the user's latest booking
+    last_period  DATE NULL,      -- monday of the last week handled
+    last_sent_at TIMESTAMP NULL, -- last digest sent, weeks with nothing to send leave it
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE,
+    INDEX idx_period (opted_out, last_period)
+);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS waitlist (
+    id               INT AUTO_INCREMENT PRIMARY KEY,
+    event_id         INT NOT NULL,
+    user_id          INT NOT NULL,
+    seats            INT NOT NULL CHECK (seats > 0),
+    tier_id          INT NULL,
+    status           ENUM("WAITING", "OFFERED", "CLAIMED", "EXPIRED", "LEFT") DEFAULT "WAITING",
+    hold_id          VARCHAR(64),
+    offer_expires_at TIMESTAMP NULL,
+    joined_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- user_id while the entry is WAITING or OFFERED, a user is on the
+    -- waitlist of an event once but can join again after leaving
+    active_user_id   INT AS (IF(status IN ("WAITING", "OFFERED"), user_id, NULL)) STORED,
+    FOREIGN KEY (event_id) REFERENCES event(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE,
+    FOREIGN KEY (tier_id) REFERENCES ticket_tier(id) ON DELETE CASCADE,
+    INDEX idx_event_status (event_id, status),
+    INDEX idx_hold (hold_id),
+    UNIQUE KEY uniq_active_user (event_id, active_user_id)
+);
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -13,6 +16,24 @@ import (
 
 var (
 	eventVerisonKey string = "event:v"
+	seatHoldKey     string = "event:hold"
+	waitingRoomKey  string = "event:queue"
+	bookingReqKey   string = "booking:req"
+)
+
+// queue data outlives the on-sale only by this much
+const waitingRoomTTL = 48 * time.Hour
+
+// clients poll async booking outcomes for a day at most
+const bookingReqTTL = 24 * time.Hour
+
+// search results also go stale as seats sell, which doesn't bump the
+// event version, so they are kept shorter than listings
+const searchCacheTTL = 2 * time.Minute
+
+var (
+	ErrNotInQueue       = errors.New("not in the waiting room")
+	ErrAdmissionExpired = errors.New("admission expired, join the waiting room again")
 )
 
 type RedisServer struct {
@@ -67,11 +88,13 @@ func (r *RedisServer) GetEventVerison(ctx context.Context)(int, error){
 	return n, nil;
 }
 
-func (r *RedisServer) SetEventsCache(ctx context.Context, events []models.EventCache, verison int, cursor int, limit int) error{
+// SetEventsCache caches a page of the listing, a listing filtered by
+// category or tag gets keys of its own
+func (r *RedisServer) SetEventsCache(ctx context.Context, events []models.EventCache, verison int, filter ListingFilter, cursor int, limit int) error{
 	if r == nil || r.rdx == nil {
 		return fmt.Errorf("redis not available")
 	}
-	key := getEventCacheKey(verison, cursor, limit)
+	key := getEventCacheKey(verison, filter, cursor, limit)
 	data, err := json.Marshal(events);
 	if err != nil{
 		return err;
@@ -81,12 +104,12 @@ func (r *RedisServer) SetEventsCache(ctx context.Context, events []models.EventC
 	return r.rdx.Set(ctx, key, data, 10*time.Minute).Err()
 }
 
-func (r *RedisServer) GetCacheEvents(ctx context.Context,version int, cursor int, limit int)([]models.EventCache, error){
+func (r *RedisServer) GetCacheEvents(ctx context.Context,version int, filter ListingFilter, cursor int, limit int)([]models.EventCache, error){
 	if r == nil || r.rdx == nil {
 		return nil, fmt.Errorf("redis not available")
 	}
 
-	key := getEventCacheKey(version, cursor, limit); //helper function used
+	key := getEventCacheKey(version, filter, cursor, limit); //helper function used
 	res, err := r.rdx.Get(ctx, key).Result();
 	if err != nil {
 		if err == redis.Nil {
@@ -103,11 +126,427 @@ func (r *RedisServer) GetCacheEvents(ctx context.Context,version int, cursor int
 	return events, nil
 }
 
+// SetSearchCache stores a page of search results under the event version,
+// hash identifies the query & cursor
+func (r *RedisServer) SetSearchCache(ctx context.Context, version int, hash string, page models.EventSearchCache) error {
+	if r == nil || r.rdx == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	return r.rdx.Set(ctx, getSearchCacheKey(version, hash), data, searchCacheTTL).Err()
+}
+
+// GetSearchCache returns a cached page of search results, nil on a miss
+func (r *RedisServer) GetSearchCache(ctx context.Context, version int, hash string) (*models.EventSearchCache, error) {
+	if r == nil || r.rdx == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	res, err := r.rdx.Get(ctx, getSearchCacheKey(version, hash)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var page models.EventSearchCache
+	if err := json.Unmarshal([]byte(res), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// CreateSeatHold stores the hold in the event hold hash and indexes it by
+// expiry time, so expired holds can be dropped without touching mysql.
+func (r *RedisServer) CreateSeatHold(ctx context.Context, hold models.SeatHold) error {
+	if r == nil || r.rdx == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	data, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+
+	key := getSeatHoldKey(hold.EventID)
+	_, err = r.rdx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, hold.HoldID, data)
+		pipe.ZAdd(ctx, key+":exp", redis.Z{Score: float64(hold.ExpiresAt.Unix()), Member: hold.HoldID})
+		return nil
+	})
+	return err
+}
+
+// GetActiveHolds returns all unexpired holds of an event, expired
+// holds are released here which gives their seats back to availability.
+func (r *RedisServer) GetActiveHolds(ctx context.Context, eventID int64) ([]models.SeatHold, error) {
+	if r == nil || r.rdx == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	key := getSeatHoldKey(eventID)
+	expired, err := r.rdx.ZRangeByScore(ctx, key+":exp", &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(expired) > 0 {
+		members := make([]interface{}, len(expired))
+		for i, id := range expired {
+			members[i] = id
+		}
+		if _, err := r.rdx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, key, expired...)
+			pipe.ZRem(ctx, key+":exp", members...)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := r.rdx.HVals(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	holds := make([]models.SeatHold, 0, len(res))
+	for _, v := range res {
+		var hold models.SeatHold
+		if err := json.Unmarshal([]byte(v), &hold); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+
+	return holds, nil
+}
+
+// GetHeldSeats sums the seats of all active holds of an event
+func (r *RedisServer) GetHeldSeats(ctx context.Context, eventID int64) (int64, error) {
+	holds, err := r.GetActiveHolds(ctx, eventID)
+	if err != nil {
+		return 0, err
+	}
+
+	var held int64
+	for _, hold := range holds {
+		held += hold.Seats
+	}
+	return held, nil
+}
+
+// GetSeatHold returns the hold if it is still active, nil otherwise
+func (r *RedisServer) GetSeatHold(ctx context.Context, eventID int64, holdID string) (*models.SeatHold, error) {
+	if r == nil || r.rdx == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	res, err := r.rdx.HGet(ctx, getSeatHoldKey(eventID), holdID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var hold models.SeatHold
+	if err := json.Unmarshal([]byte(res), &hold); err != nil {
+		return nil, err
+	}
+
+	// not purged yet but already expired
+	if time.Now().After(hold.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &hold, nil
+}
+
+// ReleaseSeatHold removes the hold, its seats are available again
+func (r *RedisServer) ReleaseSeatHold(ctx context.Context, eventID int64, holdID string) error {
+	if r == nil || r.rdx == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	key := getSeatHoldKey(eventID)
+	_, err := r.rdx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, key, holdID)
+		pipe.ZRem(ctx, key+":exp", holdID)
+		return nil
+	})
+	return err
+}
+
+// SetWaitingRoom saves the waiting room config of an event, the booking
+// endpoints read it on every request so it lives in redis only
+func (r *RedisServer) SetWaitingRoom(ctx context.Context, cfg models.WaitingRoomConfig) error {
+	if r == nil || r.rdx == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return r.rdx.Set(ctx, getWaitingRoomKey(cfg.EventID)+":cfg", data, 0).Err()
+}
+
+// GetWaitingRoom returns the waiting room config, nil if never set
+func (r *RedisServer) GetWaitingRoom(ctx context.Context, eventID int64) (*models.WaitingRoomConfig, error) {
+	if r == nil || r.rdx == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	res, err := r.rdx.Get(ctx, getWaitingRoomKey(eventID)+":cfg").Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var cfg models.WaitingRoomConfig
+	if err := json.Unmarshal([]byte(res), &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// JoinWaitingRoom gives the user the next queue number, joining again
+// keeps the number already given
+func (r *RedisServer) JoinWaitingRoom(ctx context.Context, eventID, userID int64) (int64, error) {
+	if r == nil || r.rdx == nil {
+		return 0, fmt.Errorf("redis not available")
+	}
+
+	key := getWaitingRoomKey(eventID)
+	member := strconv.FormatInt(userID, 10)
+
+	score, err := r.rdx.ZScore(ctx, key, member).Result()
+	if err == nil {
+		return int64(score), nil
+	}
+	if err != redis.Nil {
+		return 0, err
+	}
+
+	seq, err := r.rdx.Incr(ctx, key+":seq").Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// NX: a concurrent join of the same user keeps the first number
+	added, err := r.rdx.ZAddNX(ctx, key, redis.Z{Score: float64(seq), Member: member}).Result()
+	if err != nil {
+		return 0, err
+	}
+	if added == 0 {
+		score, err := r.rdx.ZScore(ctx, key, member).Result()
+		return int64(score), err
+	}
+
+	r.rdx.Expire(ctx, key, waitingRoomTTL)
+	r.rdx.Expire(ctx, key+":seq", waitingRoomTTL)
+	return seq, nil
+}
+
+// WaitingRoomStatus tells the user how many are ahead, once their batch
+// is admitted a short lived admission token is issued (only once per
+// queue number, an expired admission means joining again at the back)
+func (r *RedisServer) WaitingRoomStatus(ctx context.Context, cfg models.WaitingRoomConfig, userID int64) (*models.WaitingRoomStatus, error) {
+	if r == nil || r.rdx == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	key := getWaitingRoomKey(cfg.EventID)
+	member := strconv.FormatInt(userID, 10)
+
+	score, err := r.rdx.ZScore(ctx, key, member).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotInQueue
+		}
+		return nil, err
+	}
+
+	status := &models.WaitingRoomStatus{EventID: cfg.EventID, QueueNumber: int64(score)}
+	admittedUpTo := cfg.AdmittedUpTo(time.Now())
+
+	if status.QueueNumber > admittedUpTo {
+		ahead, err := r.rdx.ZCount(ctx, key, fmt.Sprintf("(%d", admittedUpTo), fmt.Sprintf("(%d", status.QueueNumber)).Result()
+		if err != nil {
+			return nil, err
+		}
+		status.Ahead = ahead
+		return status, nil
+	}
+
+	userKey := fmt.Sprintf("%s:user:%d", key, userID)
+	ttl := cfg.TokenTTL()
+
+	token, err := r.rdx.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if err == redis.Nil {
+		wasAdmitted, err := r.rdx.SIsMember(ctx, key+":admitted", member).Result()
+		if err != nil {
+			return nil, err
+		}
+		if wasAdmitted {
+			if _, err := r.rdx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.ZRem(ctx, key, member)
+				pipe.SRem(ctx, key+":admitted", member)
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			return nil, ErrAdmissionExpired
+		}
+
+		token, err = newAdmissionToken()
+		if err != nil {
+			return nil, err
+		}
+		// NX: concurrent polls of the same user share one token
+		ok, err := r.rdx.SetNX(ctx, userKey, token, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if token, err = r.rdx.Get(ctx, userKey).Result(); err != nil {
+				return nil, err
+			}
+		} else if _, err := r.rdx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key+":token:"+token, member, ttl)
+			pipe.SAdd(ctx, key+":admitted", member)
+			pipe.Expire(ctx, key+":admitted", waitingRoomTTL)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	left, err := r.rdx.TTL(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(left)
+
+	status.Admitted = true
+	status.Token = token
+	status.TokenExpiresAt = &expiresAt
+	return status, nil
+}
+
+// ValidAdmission reports if token is a live admission token of the user
+func (r *RedisServer) ValidAdmission(ctx context.Context, eventID, userID int64, token string) (bool, error) {
+	if r == nil || r.rdx == nil {
+		return false, fmt.Errorf("redis not available")
+	}
+	if token == "" {
+		return false, nil
+	}
+
+	res, err := r.rdx.Get(ctx, getWaitingRoomKey(eventID)+":token:"+token).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+	return res == strconv.FormatInt(userID, 10), nil
+}
+
+// SetBookingRequestStatus stores the latest outcome of an async booking request
+func (r *RedisServer) SetBookingRequestStatus(ctx context.Context, status models.AsyncBookingStatus) error {
+	if r == nil || r.rdx == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return r.rdx.Set(ctx, getBookingReqKey(status.RequestID), data, bookingReqTTL).Err()
+}
+
+// GetBookingRequestStatus returns the outcome of an async booking request,
+// nil if unknown or too old
+func (r *RedisServer) GetBookingRequestStatus(ctx context.Context, requestID string) (*models.AsyncBookingStatus, error) {
+	if r == nil || r.rdx == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	res, err := r.rdx.Get(ctx, getBookingReqKey(requestID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var status models.AsyncBookingStatus
+	if err := json.Unmarshal([]byte(res), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func newAdmissionToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // func (r *RedisServer)InvalidateEventsCache(ctx context.Context) error {
 // 	return r.rdx.Del(ctx, cacheAllEventKey).Err()
 // }
 
 
-func getEventCacheKey(version int, cursor int, limit int) string {
-	return fmt.Sprintf("%s:%d:c:%d:l:%d", eventVerisonKey, version, cursor, limit)
-}
\ No newline at end of file
+// ListingFilter narrows the event listing down, names are normalized
+// (models.NormalizeTag) so they are safe in keys
+type ListingFilter struct {
+	Category string
+	Tag      string
+}
+
+// e.g. event:v:3:cat:music:c:0:l:10, the unfiltered listing keeps its
+// old keys
+func getEventCacheKey(version int, filter ListingFilter, cursor int, limit int) string {
+	key := fmt.Sprintf("%s:%d", eventVerisonKey, version)
+	if filter.Category != "" {
+		key += ":cat:" + filter.Category
+	}
+	if filter.Tag != "" {
+		key += ":tag:" + filter.Tag
+	}
+	return fmt.Sprintf("%s:c:%d:l:%d", key, cursor, limit)
+}
+
+func getSearchCacheKey(version int, hash string) string {
+	return fmt.Sprintf("%s:%d:search:%s", eventVerisonKey, version, hash)
+}
+
+func getSeatHoldKey(eventID int64) string {
+	return fmt.Sprintf("%s:%d", seatHoldKey, eventID)
+}
+
+func getBookingReqKey(requestID string) string {
+	return fmt.Sprintf("%s:%s", bookingReqKey, requestID)
+}
+
+func getWaitingRoomKey(eventID int64) string {
+	return fmt.Sprintf("%s:%d", waitingRoomKey, eventID)
+}